@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -10,18 +12,33 @@ import (
 
 	"github.com/Illusiard/miniapi/internal/app"
 	"github.com/Illusiard/miniapi/internal/config"
+	"github.com/Illusiard/miniapi/internal/httpserver"
 )
 
 func main() {
+	// спецификация строится без базы и от окружения не зависит, поэтому неверные
+	// настройки БД не мешают генерировать её в CI
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		os.Exit(dumpOpenAPI(os.Stdout, os.Stderr))
+	}
+	// migrate сам загружает конфиг, когда нужна база: create только пишет файлы
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		// Ctrl+C прерывает текущую миграцию, а не только ожидание
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := runMigrate(ctx, config.Load, os.Args[2:], os.Stdout, os.Stderr)
+		stop()
+		os.Exit(code)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		slog.Error("config load failed", "error", err)
 		os.Exit(1)
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	logger := slog.New(httpserver.NewLogHandler(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: cfg.LogLevel,
-	}))
+	})))
 	slog.SetDefault(logger)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -47,3 +64,25 @@ func main() {
 
 	slog.Info("bye")
 }
+
+// dumpOpenAPI печатает документ в stdout, логи уходят в stderr, чтобы не портить вывод.
+// Конфиг не нужен: модули регистрируются с хранилищами-заглушками.
+func dumpOpenAPI(stdout, stderr io.Writer) int {
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+
+	doc, err := app.New(config.Config{}).OpenAPI()
+	if err != nil {
+		slog.Error("openapi build failed", "error", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		slog.Error("openapi encode failed", "error", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDumpOpenAPIWithoutDatabaseEnv(t *testing.T) {
+	// с таким окружением config.Load падает, а спецификация от него не зависит
+	t.Setenv("DB_SSLMODE", "bogus")
+	t.Setenv("DB_MAX_CONNS", "-1")
+
+	var out, errOut bytes.Buffer
+	if code := dumpOpenAPI(&out, &errOut); code != 0 {
+		t.Fatalf("openapi: %d %s", code, errOut.String())
+	}
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil || doc.OpenAPI == "" || doc.Paths["/notes"] == nil {
+		t.Fatalf("unexpected document: %v %.200s", err, out.String())
+	}
+}
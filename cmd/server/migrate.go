@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/Illusiard/miniapi/internal/app"
+	"github.com/Illusiard/miniapi/internal/config"
+	"github.com/Illusiard/miniapi/internal/migrations"
+)
+
+const migrateUsage = `usage: server migrate <command> [-module NAME]
+
+commands:
+  up               apply all pending migrations of every module, in module order
+  down N           roll back N migrations of the module
+  goto V           migrate the module up or down to version V
+  force V          set the module version to V without running migrations and clear dirty (-1: nothing applied)
+  status           print applied and pending migrations of every module
+  create NAME      create an empty NNNNNN_NAME.up.sql/.down.sql pair in modules/<module>/migrations
+
+-module may be omitted when only one module has migrations.
+`
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// runMigrate выполняет `server migrate …`: 0 — успех, 1 — ошибка миграции, 2 — неверные аргументы.
+// Результат печатается в stdout, ошибки и логи — в stderr. load вызывается только
+// для команд, которым нужна база.
+func runMigrate(ctx context.Context, load func() (config.Config, error), args []string, stdout, stderr io.Writer) int {
+	slog.SetDefault(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+
+	if len(args) == 0 {
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+	cmd := args[0]
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, migrateUsage) }
+	module := fs.String("module", "", "module to migrate")
+	pos, err := parseInterleaved(fs, args[1:])
+	if err != nil {
+		return 2
+	}
+
+	var runner *migrations.Runner
+	var mods []string
+	if cmd == "create" {
+		// create только пишет файлы: настройки баз ему не нужны и могут быть неверны
+		mods = app.New(config.Config{}).MigrationModules()
+	} else {
+		cfg, err := load()
+		if err != nil {
+			fmt.Fprintf(stderr, "migrate: config: %v\n", err)
+			return 1
+		}
+		if runner, err = app.New(cfg).Migrations(); err != nil {
+			fmt.Fprintf(stderr, "migrate: %v\n", err)
+			return 1
+		}
+		mods = runner.Modules()
+	}
+
+	usage := func(format string, a ...any) int {
+		fmt.Fprintf(stderr, "migrate %s: %s\n\n%s", cmd, fmt.Sprintf(format, a...), migrateUsage)
+		return 2
+	}
+	needArgs := func(n int) bool { return len(pos) == n }
+
+	switch cmd {
+	case "up", "status":
+		if !needArgs(0) || *module != "" {
+			return usage("applies to every module and takes no arguments")
+		}
+	case "down", "goto", "force", "create":
+		if !needArgs(1) {
+			return usage("expects exactly one argument")
+		}
+		if *module, err = pickModule(mods, *module); err != nil {
+			return usage("%v", err)
+		}
+	default:
+		return usage("unknown command")
+	}
+
+	switch cmd {
+	case "up":
+		err = runner.Up(ctx)
+	case "down":
+		n, perr := strconv.Atoi(pos[0])
+		if perr != nil || n < 1 {
+			return usage("N must be a positive integer, got %q", pos[0])
+		}
+		err = runner.Down(ctx, *module, n)
+	case "goto":
+		v, perr := strconv.ParseUint(pos[0], 10, 64)
+		if perr != nil {
+			return usage("V must be a version number, got %q", pos[0])
+		}
+		err = runner.Goto(ctx, *module, uint(v))
+	case "force":
+		v, perr := strconv.Atoi(pos[0])
+		if perr != nil || v < -1 {
+			return usage("V must be a version number or -1, got %q", pos[0])
+		}
+		err = runner.Force(ctx, *module, v)
+	case "create":
+		if !migrationName.MatchString(pos[0]) {
+			return usage("NAME must match [a-z0-9_]+, got %q", pos[0])
+		}
+		return createMigration(filepath.Join("modules", *module, "migrations"), pos[0], stdout, stderr)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate %s: %v\n", cmd, err)
+		var dirty migrate.ErrDirty
+		if errors.As(err, &dirty) {
+			fmt.Fprintf(stderr, "the schema is dirty at version %d: fix it by hand, then run `server migrate force <version> -module <module>`\n", dirty.Version)
+		}
+		return 1
+	}
+
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate status: %v\n", err)
+		return 1
+	}
+	printStatus(stdout, statuses)
+	for _, st := range statuses {
+		if st.Dirty {
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseInterleaved разрешает флаги и после позиционных аргументов: `down 1 -module notes`.
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos, args = append(pos, args[0]), args[1:]
+	}
+}
+
+// pickModule проверяет -module, а без него берёт единственный модуль с миграциями.
+func pickModule(mods []string, module string) (string, error) {
+	if module == "" {
+		if len(mods) != 1 {
+			return "", fmt.Errorf("-module is required, modules with migrations: %v", mods)
+		}
+		return mods[0], nil
+	}
+	for _, m := range mods {
+		if m == module {
+			return module, nil
+		}
+	}
+	return "", fmt.Errorf("module %q has no migrations, modules with migrations: %v", module, mods)
+}
+
+func printStatus(w io.Writer, statuses []migrations.Status) {
+	for _, st := range statuses {
+		state := "clean"
+		if st.Dirty {
+			state = "DIRTY"
+		}
+		fmt.Fprintf(w, "%s (%s): version %d of %d, %s\n", st.Module, st.Table, st.Version, st.Latest, state)
+		for _, m := range st.Migrations {
+			fmt.Fprintf(w, "  %-7s %06d %s\n", m.State, m.Version, m.Name)
+		}
+	}
+}
+
+// createMigration создаёт пустую пару файлов со следующим номером в dir.
+func createMigration(dir, name string, stdout, stderr io.Writer) int {
+	next, err := nextVersion(dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate create: %v\n", err)
+		return 1
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", next, name))
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		f, err := os.OpenFile(base+suffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			fmt.Fprintf(stderr, "migrate create: %v\n", err)
+			return 1
+		}
+		_ = f.Close()
+		fmt.Fprintln(stdout, base+suffix)
+	}
+	return 0
+}
+
+// nextVersion — номер после наибольшего в dir (каталог должен существовать).
+func nextVersion(dir string) (uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("read %s (run from the repository root): %w", dir, err)
+	}
+	var last uint64
+	for _, e := range entries {
+		var v uint64
+		if _, err := fmt.Sscanf(e.Name(), "%d_", &v); err == nil && v > last {
+			last = v
+		}
+	}
+	return last + 1, nil
+}
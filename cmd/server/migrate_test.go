@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Illusiard/miniapi/internal/config"
+	"github.com/Illusiard/miniapi/internal/migrations"
+)
+
+func TestParseInterleaved(t *testing.T) {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	module := fs.String("module", "", "")
+
+	pos, err := parseInterleaved(fs, []string{"1", "-module", "notes"})
+	if err != nil || len(pos) != 1 || pos[0] != "1" || *module != "notes" {
+		t.Fatalf("got %v %q %v", pos, *module, err)
+	}
+	if _, err := parseInterleaved(fs, []string{"-nope"}); err == nil {
+		t.Fatal("unknown flag must fail")
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	dir := t.TempDir()
+	if v, err := nextVersion(dir); err != nil || v != 1 {
+		t.Fatalf("empty dir: %d, %v", v, err)
+	}
+	for _, name := range []string{"000002_b.up.sql", "000010_c.down.sql", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, err := nextVersion(dir); err != nil || v != 11 {
+		t.Fatalf("got %d, %v", v, err)
+	}
+
+	var out, errOut bytes.Buffer
+	if code := createMigration(dir, "add_index", &out, &errOut); code != 0 {
+		t.Fatalf("create: %d %s", code, errOut.String())
+	}
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		if _, err := os.Stat(filepath.Join(dir, "000011_add_index"+suffix)); err != nil {
+			t.Fatalf("missing %s: %v", suffix, err)
+		}
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	var buf bytes.Buffer
+	printStatus(&buf, []migrations.Status{{
+		Module: "notes", Table: "schema_migrations_notes", Version: 2, Latest: 3, Dirty: true,
+		Migrations: []migrations.Migration{
+			{Version: 1, Name: "create_notes", State: migrations.StateApplied},
+			{Version: 2, Name: "notes_search", State: migrations.StateDirty},
+			{Version: 3, Name: "notes_soft_delete", State: migrations.StatePending},
+		},
+	}})
+	want := `notes (schema_migrations_notes): version 2 of 3, DIRTY
+  applied 000001 create_notes
+  dirty   000002 notes_search
+  pending 000003 notes_soft_delete
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunMigrateConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := filepath.Join("modules", "notes", "migrations")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	badConfig := func() (config.Config, error) { return config.Config{}, errors.New("invalid DB_SSLMODE") }
+
+	// create не читает настройки баз, поэтому неверный конфиг ему не мешает
+	var out, errOut bytes.Buffer
+	if code := runMigrate(context.Background(), badConfig, []string{"create", "add_index", "-module", "notes"}, &out, &errOut); code != 0 {
+		t.Fatalf("create: %d %s", code, errOut.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "000001_add_index.up.sql")); err != nil {
+		t.Fatalf("missing migration: %v", err)
+	}
+
+	errOut.Reset()
+	if code := runMigrate(context.Background(), badConfig, []string{"status"}, &out, &errOut); code != 1 ||
+		!strings.Contains(errOut.String(), "invalid DB_SSLMODE") {
+		t.Fatalf("status with bad config: %d %s", code, errOut.String())
+	}
+}
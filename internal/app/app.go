@@ -3,31 +3,51 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/Illusiard/miniapi/internal/caps"
 	"github.com/Illusiard/miniapi/internal/config"
 	"github.com/Illusiard/miniapi/internal/db"
+	"github.com/Illusiard/miniapi/internal/drift"
 	"github.com/Illusiard/miniapi/internal/httpserver"
 	"github.com/Illusiard/miniapi/internal/meta"
 	"github.com/Illusiard/miniapi/internal/migrations"
 	"github.com/Illusiard/miniapi/internal/modules"
+	"github.com/Illusiard/miniapi/internal/openapi"
 	"github.com/Illusiard/miniapi/internal/store"
+	"github.com/Illusiard/miniapi/modules/entities"
 	"github.com/Illusiard/miniapi/modules/notes"
 	"github.com/Illusiard/miniapi/modules/ping"
 )
 
+const apiVersion = "0.1.0"
+
 type App struct {
 	cfg    config.Config
 
-	db     *pgxpool.Pool
-	server *httpserver.Server
+	// pools — пулы соединений по имени базы, "" — основная база.
+	pools  map[string]*pgxpool.Pool
+	// replicas — пулы реплик основной базы.
+	replicas []*pgxpool.Pool
+	server   *httpserver.Server
+	// migrations — миграции модулей; nil, пока App не запущен.
+	migrations *migrations.Runner
+
+	stopChecks context.CancelFunc
+
+	stopRunners context.CancelFunc
+	runners     sync.WaitGroup
 }
 
 func New(cfg config.Config) *App {
@@ -35,100 +55,549 @@ func New(cfg config.Config) *App {
 }
 
 func (a *App) Start(ctx context.Context) error {
-	slog.Info("starting http server", "addr", a.cfg.HTTPAddr)
 	connCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	pool, err := db.Connect(connCtx, a.cfg.DatabaseURL)
-	if err != nil {
+	specs := moduleSpecs(a.cfg)
+	if err := a.connect(connCtx, specs); err != nil {
 		return err
 	}
-	a.db = pool
 
+	sets, err := migrationSets(a.cfg, specs)
+	if err != nil {
+		return err
+	}
+	a.migrations = newMigrations(a.cfg, sets)
 	if a.cfg.AutoMigrate {
-		slog.Info("auto-migrate enabled", "path", a.cfg.MigrationsPath)
-		r := migrations.New(a.cfg.MigrationsPath, a.cfg.DatabaseURL)
-		if err := r.Up(); err != nil {
+		slog.Info("auto-migrate enabled", "modules", len(sets))
+		// ctx старта: сигнал остановки прерывает и миграции
+		if err := a.migrations.Up(ctx); err != nil {
 			return fmt.Errorf("auto-migrate: %w", err)
 		}
 	}
+	// свой лимит: connCtx мог истечь, пока шли миграции
+	schemaCtx, cancelSchema := context.WithTimeout(ctx, 5*time.Second)
+	schemaReady, err := checkSchema(schemaCtx, a.cfg.MigrationsCheck, a.migrations)
+	cancelSchema()
+	if err != nil {
+		return err
+	}
 
-	pgStore := store.New(a.db)
+	storeOpts := []store.Option{
+		store.WithMaxRetries(a.cfg.DBTxMaxRetries),
+		store.WithRetryBackoff(a.cfg.DBTxRetryBackoff),
+		store.WithLogger(slog.Default()),
+	}
+	stores := make(map[string]caps.Store, len(a.pools))
+	for name, pool := range a.pools {
+		if name != "" {
+			stores[name] = store.New(pool, storeOpts...)
+		}
+	}
+	// реплики есть только у основной базы
+	primary := store.New(a.pools[""], append(storeOpts, store.WithReplicas(a.replicas...))...)
+	stores[""] = primary
+	a.startReplicaChecks(ctx, primary)
 
 	readyFn := func(ctx context.Context) error {
-		return pgStore.Ping(ctx)
+		for name, st := range stores {
+			if err := st.Ping(ctx); err != nil {
+				return fmt.Errorf("database %q: %w", dbLabel(name), err)
+			}
+		}
+		if schemaReady != nil {
+			return schemaReady(ctx)
+		}
+		return nil
 	}
 
 	metaReg := meta.New()
 
-	registerFn := func(r chi.Router) {
-		r.Get("/meta/entities", func(w http.ResponseWriter, req *http.Request) {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			_ = json.NewEncoder(w).Encode(metaReg.Entities())
-		})
-		r.Get("/meta/modules", func(w http.ResponseWriter, req *http.Request) {
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			_ = json.NewEncoder(w).Encode(metaReg.Modules())
-		})
-
-
-		specs := []modules.Spec{
-			{
-				Module:      ping.New(),
-				WithStore:   false,
-				Description: "Demo module: /ping endpoint + publishes Ping entity metadata.",
-				Version:     "0.1.0",
-			},
-			{
-				Module:      notes.New(),
-				WithStore:   true,
-				Description: "Example CRUD module backed by PostgreSQL (notes table).",
-				Version:     "0.1.0",
-			},
-		}
-
-		for _, spec := range specs {
-			m := spec.Module
-			slog.Info("registering module", "module", m.Name())
-
-			setup := caps.Setup{
-				Routes: caps.NewChiRoutes(r),
-				Meta:   metaReg,
-				Log:    slog.Default(),
+	registerFn := func(r chi.Router) error {
+		return a.mount(r, metaReg, stores, specs)
+	}
+
+	a.server, err = httpserver.New(a.cfg.HTTPAddr, readyFn, registerFn, httpserver.Options{
+		RequestID: a.cfg.HTTPRequestID,
+		AccessLog: a.cfg.HTTPAccessLog,
+		Recover:   a.cfg.HTTPRecover,
+		ReadyInfo: replicaInfo(primary),
+	})
+	if err != nil {
+		return err
+	}
+
+	// сущности публикуются при регистрации модулей, поэтому сверяем после httpserver.New
+	driftCtx, cancelDrift := context.WithTimeout(ctx, 5*time.Second)
+	err = checkDrift(driftCtx, a.cfg.SchemaDriftCheck, metaReg, stores)
+	cancelDrift()
+	if err != nil {
+		return err
+	}
+
+	// server.Start блокируется до остановки, поэтому фоновая работа модулей запускается до него
+	a.startRunners(ctx, specs)
+
+	slog.Info("starting http server", "addr", a.cfg.HTTPAddr)
+	if err := a.server.Start(ctx); err != nil {
+		a.stopRunners()
+		a.runners.Wait()
+		return fmt.Errorf("http server: %w", err)
+	}
+
+	return nil
+}
+
+// connect открывает пул основной базы и именованных баз, которые запрошены модулями.
+func (a *App) connect(ctx context.Context, specs []modules.Spec) error {
+	a.pools = map[string]*pgxpool.Pool{}
+
+	pool, err := db.Connect(ctx, a.cfg.DatabaseURL, a.cfg.DBPool)
+	if err != nil {
+		return err
+	}
+	a.pools[""] = pool
+
+	for _, spec := range specs {
+		name := spec.Database
+		if _, ok := a.pools[name]; ok {
+			continue
+		}
+		url, ok := a.cfg.Databases[name]
+		if !ok {
+			return fmt.Errorf("module %s: database %q is not configured (set DATABASE_URL_%s)",
+				spec.Module.Name(), name, strings.ToUpper(name))
+		}
+		slog.Info("connecting named database", "database", name, "module", spec.Module.Name())
+		if a.pools[name], err = db.Connect(ctx, url, a.cfg.DBPool); err != nil {
+			return fmt.Errorf("database %q: %w", name, err)
+		}
+	}
+
+	for i, url := range a.cfg.ReplicaURLs {
+		pool, err := db.Open(ctx, url, a.cfg.DBPool)
+		if err != nil {
+			return fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		a.replicas = append(a.replicas, pool)
+	}
+	if len(a.replicas) > 0 {
+		slog.Info("read replicas configured", "count", len(a.replicas))
+	}
+	return nil
+}
+
+// startReplicaChecks периодически проверяет реплики, чтобы чтение не уходило в упавшую.
+func (a *App) startReplicaChecks(ctx context.Context, st *store.PGStore) {
+	if len(a.replicas) == 0 {
+		return
+	}
+	checkCtx, cancel := context.WithCancel(ctx)
+	a.stopChecks = cancel
+	a.runners.Add(1)
+	go func() {
+		defer a.runners.Done()
+		st.RunReplicaChecks(checkCtx, a.cfg.ReplicaCheckInterval)
+	}()
+}
+
+// replicaInfo отдаёт состояние реплик в /ready отдельно от статуса: если все
+// реплики недоступны, чтение идёт в основную базу и сервис остаётся готов.
+func replicaInfo(st *store.PGStore) func(ctx context.Context) map[string]any {
+	if len(st.Replicas()) == 0 {
+		return nil
+	}
+	return func(ctx context.Context) map[string]any {
+		replicas := st.CheckReplicas(ctx)
+		healthy := 0
+		for _, r := range replicas {
+			if r.Healthy {
+				healthy++
 			}
-			if spec.WithStore {
-				setup.Store = pgStore
+		}
+		state := "ok"
+		switch healthy {
+		case len(replicas):
+		case 0:
+			state = "down"
+		default:
+			state = "degraded"
+		}
+		return map[string]any{"replicas": map[string]any{
+			"status":  state,
+			"healthy": healthy,
+			"total":   len(replicas),
+			"items":   replicas,
+		}}
+	}
+}
+
+// Migrations возвращает Runner с миграциями модулей без подключения к БД и старта сервера.
+func (a *App) Migrations() (*migrations.Runner, error) {
+	sets, err := migrationSets(a.cfg, moduleSpecs(a.cfg))
+	if err != nil {
+		return nil, err
+	}
+	return newMigrations(a.cfg, sets), nil
+}
+
+// MigrationModules — модули с миграциями в порядке запуска. Базы для этого не нужны,
+// поэтому годится и App с пустым config.Config.
+func (a *App) MigrationModules() []string {
+	var mods []string
+	for _, spec := range moduleSpecs(a.cfg) {
+		if _, ok := spec.Module.(modules.Migrator); ok {
+			mods = append(mods, spec.Module.Name())
+		}
+	}
+	return mods
+}
+
+func newMigrations(cfg config.Config, sets []migrations.Set) *migrations.Runner {
+	return migrations.New(sets,
+		migrations.WithLockTimeout(cfg.MigrationsLockTimeout),
+		migrations.WithStatementTimeout(cfg.MigrationsStatementTimeout),
+		migrations.WithTimeout(cfg.MigrationsTimeout),
+		migrations.WithRollback(cfg.MigrationsRollback),
+	)
+}
+
+// checkSchema сверяет версии схемы модулей с вшитыми миграциями согласно MIGRATIONS_CHECK:
+// fail не даёт стартовать, warn пишет в лог, а degraded возвращает проверку для /ready,
+// которая не пускает трафик, пока схему не обновят (после этого больше не ходит в БД).
+func checkSchema(ctx context.Context, mode string, runner *migrations.Runner) (func(ctx context.Context) error, error) {
+	if mode == config.MigrationsCheckOff {
+		return nil, nil
+	}
+	err := runner.Check(ctx)
+	if err == nil {
+		return nil, nil
+	}
+
+	switch mode {
+	case config.MigrationsCheckFail:
+		return nil, fmt.Errorf("schema is not up to date (run `server migrate up` or set AUTO_MIGRATE=1): %w", err)
+	case config.MigrationsCheckWarn:
+		slog.Warn("schema is not up to date, run `server migrate up`", "error", err)
+		return nil, nil
+	}
+
+	slog.Warn("schema is not up to date, /ready reports not ready until it is migrated", "error", err)
+	var current atomic.Bool
+	return func(ctx context.Context) error {
+		if current.Load() {
+			return nil
+		}
+		if err := runner.Check(ctx); err != nil {
+			return fmt.Errorf("schema: %w", err)
+		}
+		slog.Info("schema is up to date, ready")
+		current.Store(true)
+		return nil
+	}, nil
+}
+
+// checkDrift сверяет сущности с таблицами согласно SCHEMA_DRIFT_CHECK:
+// fail не даёт стартовать, warn пишет расхождения в лог.
+func checkDrift(ctx context.Context, mode string, metaReg *meta.Registry, stores map[string]caps.Store) error {
+	if mode == config.DriftCheckOff {
+		return nil
+	}
+	reports, err := schemaDrift(ctx, metaReg, stores)
+	if err == nil {
+		errs := make([]error, 0, len(reports))
+		for _, r := range reports {
+			errs = append(errs, r.Err())
+		}
+		err = errors.Join(errs...)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if mode == config.DriftCheckFail {
+		return fmt.Errorf("entities do not match the database schema: %w", err)
+	}
+	slog.Warn("entities do not match the database schema, see /meta/drift", "error", err)
+	return nil
+}
+
+// schemaDrift сверяет каждую сущность с таблицей в базе её модуля; сущности без таблицы пропускаются.
+func schemaDrift(ctx context.Context, metaReg *meta.Registry, stores map[string]caps.Store) ([]drift.Report, error) {
+	databases := map[string]string{}
+	for _, m := range metaReg.Modules() {
+		databases[m.Name] = m.Database
+	}
+
+	reports := []drift.Report{}
+	for _, e := range metaReg.Entities() {
+		if e.Table == "" {
+			continue
+		}
+		st, ok := stores[databases[e.Module]]
+		if !ok {
+			return nil, fmt.Errorf("entity %s: unknown database %q", e.Name, databases[e.Module])
+		}
+		r, err := drift.Check(ctx, st, e)
+		if err != nil {
+			return nil, fmt.Errorf("entity %s: %w", e.Name, err)
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// migrationSets собирает миграции модулей, реализующих modules.Migrator, в порядке specs.
+func migrationSets(cfg config.Config, specs []modules.Spec) ([]migrations.Set, error) {
+	var sets []migrations.Set
+	for _, spec := range specs {
+		m, ok := spec.Module.(modules.Migrator)
+		if !ok {
+			continue
+		}
+		if !spec.WithStore {
+			return nil, fmt.Errorf("module %s: migrations require WithStore", spec.Module.Name())
+		}
+		url := cfg.DatabaseURL
+		if spec.Database != "" {
+			if url, ok = cfg.Databases[spec.Database]; !ok {
+				return nil, fmt.Errorf("module %s: database %q is not configured (set DATABASE_URL_%s)",
+					spec.Module.Name(), spec.Database, strings.ToUpper(spec.Database))
 			}
+		}
+		sets = append(sets, migrations.Set{Module: spec.Module.Name(), FS: m.Migrations(), DatabaseURL: url})
+	}
+	return sets, nil
+}
 
-			if err := m.Register(setup); err != nil {
-				panic(fmt.Errorf("module %s register: %w", m.Name(), err))
+func dbLabel(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+// offlineStores — заглушки для всех баз, запрошенных модулями.
+func offlineStores(specs []modules.Spec) map[string]caps.Store {
+	out := map[string]caps.Store{"": offlineStore{}}
+	for _, spec := range specs {
+		out[spec.Database] = offlineStore{}
+	}
+	return out
+}
+
+// startRunners запускает фоновую работу модулей, реализующих modules.Runner.
+func (a *App) startRunners(ctx context.Context, specs []modules.Spec) {
+	runCtx, cancel := context.WithCancel(ctx)
+	a.stopRunners = cancel
+
+	for _, spec := range specs {
+		runner, ok := spec.Module.(modules.Runner)
+		if !ok {
+			continue
+		}
+		slog.Info("starting module runner", "module", spec.Module.Name())
+		a.runners.Add(1)
+		go func() {
+			defer a.runners.Done()
+			runner.Run(runCtx)
+		}()
+	}
+}
+
+// OpenAPI собирает документ без подключения к БД: модули регистрируются с заглушкой Store.
+func (a *App) OpenAPI() (openapi.Document, error) {
+	metaReg := meta.New()
+	specs := moduleSpecs(a.cfg)
+	if err := a.mount(chi.NewRouter(), metaReg, offlineStores(specs), specs); err != nil {
+		return openapi.Document{}, err
+	}
+	return buildOpenAPI(metaReg), nil
+}
+
+// mount подключает мета-эндпоинты и модули внутри группы с globalMiddleware,
+// поэтому /health и /ready остаются без них.
+func (a *App) mount(r chi.Router, metaReg *meta.Registry, stores map[string]caps.Store, specs []modules.Spec) error {
+	var err error
+	r.Group(func(r chi.Router) {
+		r.Use(globalMiddleware()...)
+		err = a.mountModules(r, metaReg, stores, specs)
+	})
+	return err
+}
+
+func (a *App) mountModules(r chi.Router, metaReg *meta.Registry, stores map[string]caps.Store, specs []modules.Spec) error {
+	var err error
+	r.Group(func(r chi.Router) {
+		r.Use(httpserver.TagModule("meta"))
+		err = mountMeta(caps.NewModuleRoutes(r, "meta", metaReg), metaReg, stores, a.migrations)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		if err := registerModule(r, spec, metaReg, stores); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func mountMeta(routes *caps.ChiRoutes, metaReg *meta.Registry, stores map[string]caps.Store, runner *migrations.Runner) error {
+	routes.Get("/meta/entities", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(metaReg.Entities())
+	}, caps.Summary("List registered entities"), caps.Returns(http.StatusOK, []meta.Entity{}))
+	routes.Get("/meta/modules", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(metaReg.Modules())
+	}, caps.Summary("List registered modules"), caps.Returns(http.StatusOK, []meta.Module{}))
+	routes.Get("/meta/migrations", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		statuses := []migrations.Status{}
+		if runner != nil {
+			var err error
+			if statuses, err = runner.Status(req.Context()); err != nil {
+				slog.ErrorContext(req.Context(), "migration status failed", "error", err)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "migrations_unavailable"})
+				return
 			}
-			metaReg.AddModule(meta.Module{
-				Name:        m.Name(),
-				WithStore:   spec.WithStore,
-				Description: spec.Description,
-				Version:     spec.Version,
-			})
 		}
+		_ = json.NewEncoder(w).Encode(statuses)
+	}, caps.Summary("Applied, pending and dirty migrations of every module"), caps.Returns(http.StatusOK, []migrations.Status{}))
+	routes.Get("/meta/drift", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		reports, err := schemaDrift(req.Context(), metaReg, stores)
+		if err != nil {
+			slog.ErrorContext(req.Context(), "schema drift check failed", "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "drift_unavailable"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(reports)
+	}, caps.Summary("Differences between registered entities and their database tables"), caps.Returns(http.StatusOK, []drift.Report{}))
+	routes.Get("/meta/openapi.json", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(buildOpenAPI(metaReg))
+	}, caps.Summary("OpenAPI 3.1 document of the running instance"), caps.Returns(http.StatusOK, openapi.Document{}))
+	routes.Get("/meta/routes", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(metaReg.Routes())
+	}, caps.Summary("List mounted routes with owning modules"), caps.Returns(http.StatusOK, []meta.Route{}))
+
+	return routes.Err()
+}
+
+// registerModule регистрирует модуль в собственной группе chi: middleware,
+// добавленные модулем через Routes.Use, не выходят за пределы его маршрутов.
+func registerModule(r chi.Router, spec modules.Spec, metaReg *meta.Registry, stores map[string]caps.Store) error {
+	m := spec.Module
+	slog.Info("registering module", "module", m.Name())
+
+	st, ok := stores[spec.Database]
+	switch {
+	case spec.Database != "" && !spec.WithStore:
+		return fmt.Errorf("module %s: database %q requires WithStore", m.Name(), spec.Database)
+	case !ok:
+		return fmt.Errorf("module %s: unknown database %q", m.Name(), spec.Database)
 	}
 
-	a.server = httpserver.New(a.cfg.HTTPAddr, readyFn, registerFn)
+	var err error
+	r.Group(func(mr chi.Router) {
+		mr.Use(httpserver.TagModule(m.Name()))
+		routes := caps.NewModuleRoutes(mr, m.Name(), metaReg)
+		setup := caps.Setup{
+			Routes: routes,
+			Meta:   metaReg,
+			Log:    slog.Default(),
+		}
+		if spec.WithStore {
+			setup.Store = st
+		}
 
-	slog.Info("starting http server", "addr", a.cfg.HTTPAddr)
-	if err := a.server.Start(ctx); err != nil {
-		return fmt.Errorf("http server: %w", err)
+		if err = m.Register(setup); err != nil {
+			err = fmt.Errorf("module %s register: %w", m.Name(), err)
+			return
+		}
+		if err = routes.Err(); err != nil {
+			err = fmt.Errorf("module %s routes: %w", m.Name(), err)
+		}
+	})
+	if err != nil {
+		return err
 	}
 
+	metaReg.AddModule(meta.Module{
+		Name:        m.Name(),
+		WithStore:   spec.WithStore,
+		Database:    spec.Database,
+		Description: spec.Description,
+		Version:     spec.Version,
+	})
 	return nil
 }
 
+// globalMiddleware применяется ко всем модулям; модули не трогают chi напрямую.
+func globalMiddleware() []caps.Middleware {
+	return []caps.Middleware{
+		middleware.SetHeader("X-Content-Type-Options", "nosniff"),
+	}
+}
+
+func moduleSpecs(cfg config.Config) []modules.Spec {
+	return []modules.Spec{
+		{
+			Module:      ping.New(),
+			WithStore:   false,
+			Description: "Demo module: /ping endpoint + publishes Ping entity metadata.",
+			Version:     "0.1.0",
+		},
+		{
+			Module: notes.New(
+				notes.WithTrashRetention(cfg.NotesTrashRetention),
+				notes.WithPurgeInterval(cfg.NotesPurgeInterval),
+			),
+			WithStore:   true,
+			Description: "Example CRUD module backed by PostgreSQL (notes table).",
+			Version:     "0.1.0",
+		},
+		// entities монтирует маршруты по уже опубликованным сущностям, поэтому идёт последним.
+		{
+			Module:      entities.New(),
+			WithStore:   true,
+			Description: "Generic CRUD routes under /entities/{table} for registered entities with a table whose module has no routes of its own.",
+			Version:     "0.1.0",
+		},
+	}
+}
+
+func buildOpenAPI(metaReg *meta.Registry) openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:   "miniapi",
+		Version: apiVersion,
+	}, metaReg.Entities(), metaReg.Routes())
+}
+
 func (a *App) Stop(ctx context.Context) error {
 	slog.Info("stopping http server")
 	if a.server != nil {
 		_ = a.server.Stop(ctx)
 	}
-	if a.db != nil {
-		a.db.Close()
+	if a.stopChecks != nil {
+		a.stopChecks()
+	}
+	if a.stopRunners != nil {
+		a.stopRunners()
+	}
+	a.runners.Wait()
+	for _, pool := range a.pools {
+		pool.Close()
+	}
+	for _, pool := range a.replicas {
+		pool.Close()
 	}
 	return nil
 }
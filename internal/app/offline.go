@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var errOffline = errors.New("store is not available offline")
+
+type offlineStore struct{}
+
+func (offlineStore) Ping(ctx context.Context) error {
+	return errOffline
+}
+
+func (offlineStore) Exec(ctx context.Context, sql string, args ...any) error {
+	return errOffline
+}
+
+func (offlineStore) ExecResult(ctx context.Context, sql string, args ...any) (int64, error) {
+	return 0, errOffline
+}
+
+func (offlineStore) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, errOffline
+}
+
+func (offlineStore) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return offlineRow{}
+}
+
+func (offlineStore) RunInTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return errOffline
+}
+
+func (offlineStore) RunInReadTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return errOffline
+}
+
+func (offlineStore) RunInTxOpts(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	return errOffline
+}
+
+type offlineRow struct{}
+
+func (offlineRow) Scan(dest ...any) error { return errOffline }
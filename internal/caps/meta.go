@@ -5,4 +5,7 @@ import "github.com/Illusiard/miniapi/internal/meta"
 type Meta interface {
 	AddEntity(e meta.Entity)
 	AddModule(m meta.Module)
+	Entities() []meta.Entity
+	// Routes — маршруты, уже зарегистрированные модулями.
+	Routes() []meta.Route
 }
@@ -1,51 +1,181 @@
 package caps
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/Illusiard/miniapi/internal/meta"
 )
 
+type Middleware = func(http.Handler) http.Handler
+
 type Routes interface {
+	// Use добавляет middleware к текущему поддереву; вызывать до регистрации маршрутов.
+	Use(mws ...Middleware)
+	// With возвращает Routes с дополнительными middleware только для маршрутов, зарегистрированных через него.
+	With(mws ...Middleware) Routes
+	// Group создаёт группу без префикса со своим набором middleware.
+	Group(fn func(r Routes))
 	Route(pattern string, fn func(r Routes))
-	Get(pattern string, h http.HandlerFunc)
-	Post(pattern string, h http.HandlerFunc)
-	Put(pattern string, h http.HandlerFunc)
-	Delete(pattern string, h http.HandlerFunc)
+
+	Get(pattern string, h http.HandlerFunc, opts ...RouteOption)
+	Post(pattern string, h http.HandlerFunc, opts ...RouteOption)
+	Put(pattern string, h http.HandlerFunc, opts ...RouteOption)
+	Patch(pattern string, h http.HandlerFunc, opts ...RouteOption)
+	Delete(pattern string, h http.HandlerFunc, opts ...RouteOption)
+	Head(pattern string, h http.HandlerFunc, opts ...RouteOption)
+	Options(pattern string, h http.HandlerFunc, opts ...RouteOption)
 
 	Chi() chi.Router
 }
 
-type chiRoutes struct {
-	r chi.Router
+type RouteRecorder interface {
+	AddRoute(rt meta.Route) error
+	AddMount(pattern string, module string) error
+}
+
+type RouteOption func(rt *meta.Route)
+
+func Summary(s string) RouteOption {
+	return func(rt *meta.Route) { rt.Summary = s }
+}
+
+// Accepts задаёт тело запроса: значение Go-типа или meta.EntityRef.
+func Accepts(body any) RouteOption {
+	return func(rt *meta.Route) { rt.Request = body }
+}
+
+// Returns добавляет код ответа. body == nil означает пустое тело для 2xx/3xx
+// и стандартный {"error": ...} для 4xx/5xx.
+func Returns(status int, body any) RouteOption {
+	return func(rt *meta.Route) {
+		rt.Responses = append(rt.Responses, meta.Response{Status: status, Body: body})
+	}
+}
+
+// ChiRoutes — реализация Routes поверх chi. Конфликтующие регистрации не доходят до chi,
+// а накапливаются и возвращаются через Err.
+type ChiRoutes struct {
+	r      chi.Router
+	prefix string
+	module string
+	rec    RouteRecorder
+	errs   *[]error
 }
 
 func NewChiRoutes(r chi.Router) Routes {
-	return &chiRoutes{r: r}
+	return NewModuleRoutes(r, "", nil)
+}
+
+// NewModuleRoutes записывает каждый зарегистрированный маршрут в rec от имени module.
+func NewModuleRoutes(r chi.Router, module string, rec RouteRecorder) *ChiRoutes {
+	return &ChiRoutes{r: r, module: module, rec: rec, errs: new([]error)}
+}
+
+func (c *ChiRoutes) Err() error {
+	return errors.Join(*c.errs...)
+}
+
+func (c *ChiRoutes) Use(mws ...Middleware) {
+	// chi паникует, если middleware добавляют после маршрутов; превращаем это в ошибку старта.
+	defer func() {
+		if p := recover(); p != nil {
+			*c.errs = append(*c.errs, fmt.Errorf("use middleware under %q: %v", c.prefix, p))
+		}
+	}()
+	c.r.Use(mws...)
 }
 
-func (c *chiRoutes) Route(pattern string, fn func(r Routes)) {
+func (c *ChiRoutes) With(mws ...Middleware) Routes {
+	return c.sub(c.r.With(mws...), c.prefix)
+}
+
+func (c *ChiRoutes) Group(fn func(r Routes)) {
+	c.r.Group(func(gr chi.Router) {
+		fn(c.sub(gr, c.prefix))
+	})
+}
+
+func (c *ChiRoutes) Route(pattern string, fn func(r Routes)) {
+	prefix := joinPattern(c.prefix, pattern)
+	if c.rec != nil {
+		if err := c.rec.AddMount(prefix, c.module); err != nil {
+			*c.errs = append(*c.errs, err)
+			return
+		}
+	}
 	c.r.Route(pattern, func(cr chi.Router) {
-		fn(&chiRoutes{r: cr})
+		fn(c.sub(cr, prefix))
 	})
 }
 
-func (c *chiRoutes) Get(pattern string, h http.HandlerFunc) {
-	c.r.Get(pattern, h)
+func (c *ChiRoutes) Get(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodGet, pattern, h, opts)
+}
+
+func (c *ChiRoutes) Post(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodPost, pattern, h, opts)
+}
+
+func (c *ChiRoutes) Put(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodPut, pattern, h, opts)
 }
 
-func (c *chiRoutes) Post(pattern string, h http.HandlerFunc) {
-	c.r.Post(pattern, h)
+func (c *ChiRoutes) Patch(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodPatch, pattern, h, opts)
 }
 
-func (c *chiRoutes) Put(pattern string, h http.HandlerFunc) {
-	c.r.Put(pattern, h)
+func (c *ChiRoutes) Delete(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodDelete, pattern, h, opts)
 }
 
-func (c *chiRoutes) Delete(pattern string, h http.HandlerFunc) {
-	c.r.Delete(pattern, h)
+func (c *ChiRoutes) Head(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodHead, pattern, h, opts)
 }
 
-func (c *chiRoutes) Chi() chi.Router {
+func (c *ChiRoutes) Options(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	c.handle(http.MethodOptions, pattern, h, opts)
+}
+
+func (c *ChiRoutes) Chi() chi.Router {
 	return c.r
 }
+
+func (c *ChiRoutes) sub(r chi.Router, prefix string) *ChiRoutes {
+	return &ChiRoutes{r: r, prefix: prefix, module: c.module, rec: c.rec, errs: c.errs}
+}
+
+func (c *ChiRoutes) handle(method, pattern string, h http.HandlerFunc, opts []RouteOption) {
+	if c.rec != nil {
+		rt := meta.Route{
+			Method:  method,
+			Pattern: joinPattern(c.prefix, pattern),
+			Module:  c.module,
+		}
+		for _, opt := range opts {
+			opt(&rt)
+		}
+		if err := c.rec.AddRoute(rt); err != nil {
+			*c.errs = append(*c.errs, err)
+			return
+		}
+	}
+	c.r.Method(method, pattern, h)
+}
+
+// joinPattern склеивает префикс смонтированного роутера с шаблоном маршрута.
+// "/notes" + "/" даёт "/notes": chi обслуживает оба варианта.
+func joinPattern(prefix, pattern string) string {
+	p := strings.TrimSuffix(prefix, "/") + pattern
+	if len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	if p == "" {
+		p = "/"
+	}
+	return p
+}
@@ -0,0 +1,160 @@
+package caps
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+func TestModuleRoutes_RecordsPatterns(t *testing.T) {
+	reg := meta.New()
+	routes := NewModuleRoutes(chi.NewRouter(), "notes", reg)
+
+	routes.Route("/notes", func(r Routes) {
+		r.Get("/", func(w http.ResponseWriter, req *http.Request) {}, Summary("List notes"))
+		r.Delete("/{id}", func(w http.ResponseWriter, req *http.Request) {})
+	})
+	if err := routes.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := reg.Routes()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 routes, got %+v", got)
+	}
+	if got[0].Method != http.MethodGet || got[0].Pattern != "/notes" || got[0].Module != "notes" || got[0].Summary != "List notes" {
+		t.Fatalf("unexpected route: %+v", got[0])
+	}
+	if got[1].Method != http.MethodDelete || got[1].Pattern != "/notes/{id}" {
+		t.Fatalf("unexpected route: %+v", got[1])
+	}
+}
+
+func TestModuleRoutes_Conflict(t *testing.T) {
+	reg := meta.New()
+	r := chi.NewRouter()
+
+	first := NewModuleRoutes(r, "first", reg)
+	first.Get("/dup", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusOK) })
+	first.Route("/sub", func(r Routes) {})
+
+	second := NewModuleRoutes(r, "second", reg)
+	second.Get("/dup", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	second.Route("/sub", func(r Routes) {
+		t.Fatalf("conflicting mount must not be applied")
+	})
+
+	if err := first.Err(); err != nil {
+		t.Fatalf("unexpected error for first module: %v", err)
+	}
+	if err := second.Err(); !errors.Is(err, meta.ErrRouteConflict) {
+		t.Fatalf("expected ErrRouteConflict, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dup", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected first handler to stay mounted, got status %d", rec.Code)
+	}
+}
+
+func TestModuleRoutes_RouteUnderOtherModuleMount(t *testing.T) {
+	reg := meta.New()
+	r := chi.NewRouter()
+
+	first := NewModuleRoutes(r, "first", reg)
+	first.Route("/x", func(r Routes) {
+		r.Get("/{id}", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusOK) })
+	})
+
+	// корневой маршрут другого модуля под чужим префиксом затенил бы /x/{id}
+	second := NewModuleRoutes(r, "second", reg)
+	second.Get("/x/y", func(w http.ResponseWriter, req *http.Request) { w.WriteHeader(http.StatusTeapot) })
+
+	if err := first.Err(); err != nil {
+		t.Fatalf("unexpected error for first module: %v", err)
+	}
+	if err := second.Err(); !errors.Is(err, meta.ErrRouteConflict) {
+		t.Fatalf("expected ErrRouteConflict, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/x/y", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the mounted handler to serve /x/y, got status %d", rec.Code)
+	}
+}
+
+func TestModuleRoutes_MiddlewareScopes(t *testing.T) {
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Add("X-Mw", name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	ok := func(w http.ResponseWriter, req *http.Request) {}
+
+	reg := meta.New()
+	r := chi.NewRouter()
+
+	var a, b *ChiRoutes
+	r.Group(func(gr chi.Router) {
+		a = NewModuleRoutes(gr, "a", reg)
+		a.Use(tag("a"))
+		a.Get("/a", ok)
+		a.With(tag("with")).Patch("/a", ok)
+		a.Group(func(g Routes) {
+			g.Use(tag("group"))
+			g.Head("/a/group", ok)
+		})
+		a.Use(tag("late"))
+	})
+	r.Group(func(gr chi.Router) {
+		b = NewModuleRoutes(gr, "b", reg)
+		b.Options("/b", ok)
+	})
+
+	if err := a.Err(); err == nil {
+		t.Fatalf("expected error for Use after routes")
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		method, path string
+		want         []string
+	}{
+		{http.MethodGet, "/a", []string{"a"}},
+		{http.MethodPatch, "/a", []string{"a", "with"}},
+		{http.MethodHead, "/a/group", []string{"a", "group"}},
+		{http.MethodOptions, "/b", nil},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d", tc.method, tc.path, rec.Code)
+		}
+		got := rec.Header().Values("X-Mw")
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s %s: middleware got %v want %v", tc.method, tc.path, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s %s: middleware got %v want %v", tc.method, tc.path, got, tc.want)
+			}
+		}
+	}
+
+	if n := len(reg.Routes()); n != 4 {
+		t.Fatalf("expected 4 recorded routes, got %d", n)
+	}
+}
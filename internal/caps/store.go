@@ -2,12 +2,104 @@ package caps
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jackc/pgx/v5"
 )
 
+// Store — доступ модуля к БД. Одиночные чтения выполняются без транзакции
+// через Query/QueryRow, несколько согласованных чтений — через RunInReadTx.
+//
+// Если в ctx лежит транзакция этого же Store (InTx или WithTx с tx из его RunInTx*),
+// все методы работают внутри неё: Exec/Query идут в эту транзакцию, а RunInTx*
+// открывают SAVEPOINT и при ошибке fn откатываются только до него. Так хелперы
+// модуля, каждый со своим RunInTx, можно собрать в одну атомарную операцию.
+// Транзакцию другого Store (другой базы) методы не используют.
+//
+// Если настроены реплики, RunInReadTx и RunInTxOpts с AccessMode ReadOnly идут
+// в реплику, а Query/QueryRow — только с контекстом WithReadReplica: по тексту
+// запроса не отличить select от insert … returning. Остальное идёт в основную базу.
 type Store interface {
 	Ping(ctx context.Context) error
+	// Exec выполняет команду, отбрасывая результат.
 	Exec(ctx context.Context, sql string, args ...any) error
+	// ExecResult выполняет команду и возвращает число затронутых строк.
+	ExecResult(ctx context.Context, sql string, args ...any) (int64, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	RunInTx(ctx context.Context, fn func(tx pgx.Tx) error) error
+	// RunInReadTx выполняет fn в транзакции READ ONLY.
+	RunInReadTx(ctx context.Context, fn func(tx pgx.Tx) error) error
+	// RunInTxOpts задаёт уровень изоляции, режим доступа и deferrable.
+	// Все RunInTx* повторяют fn при ошибке сериализации или deadlock,
+	// поэтому fn не должна иметь побочных эффектов вне tx. Во вложенной
+	// транзакции opts не действуют, а повтор выполняет внешняя транзакция.
+	RunInTxOpts(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error
+}
+
+type txKey struct{}
+
+// WithTx возвращает контекст с транзакцией tx. Контекст годится только
+// до завершения tx, и Store использует из него лишь свои транзакции.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext возвращает транзакцию, положенную в ctx через WithTx.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+type replicaKey struct{}
+
+// WithReadReplica разрешает Query/QueryRow (и Select/Get поверх Store) с этим контекстом
+// читать из реплики. Реплика может отставать, поэтому только что записанное
+// там может быть ещё не видно. Запросы, изменяющие данные, с таким контекстом выполнять нельзя.
+func WithReadReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// ReadReplicaAllowed сообщает, помечен ли ctx через WithReadReplica.
+func ReadReplicaAllowed(ctx context.Context) bool {
+	ok, _ := ctx.Value(replicaKey{}).(bool)
+	return ok
+}
+
+// InTx выполняет fn в транзакции st (или в SAVEPOINT, если ctx уже несёт транзакцию)
+// и передаёт в fn контекст с ней: вызовы Store с этим контекстом, в том числе
+// из других хелперов, попадают в ту же транзакцию.
+func InTx(ctx context.Context, st Store, fn func(ctx context.Context, tx pgx.Tx) error) error {
+	return st.RunInTx(ctx, func(tx pgx.Tx) error {
+		return fn(WithTx(ctx, tx), tx)
+	})
+}
+
+// Querier — общее у Store и pgx.Tx, поэтому Select и Get работают с обоими.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Select выполняет запрос и раскладывает строки в структуры T: колонки сопоставляются
+// с полями по тегу db (или по имени поля без учёта регистра), у T должно быть ровно
+// столько полей, сколько колонок, `db:"-"` исключает поле. Пустой результат — пустой срез, не nil.
+func Select[T any](ctx context.Context, q Querier, sql string, args ...any) ([]T, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByName[T])
+}
+
+// Get как Select, но возвращает первую строку; found == false, если строк нет.
+func Get[T any](ctx context.Context, q Querier, sql string, args ...any) (v T, found bool, err error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return v, false, err
+	}
+	v, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[T])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return v, false, nil
+	}
+	return v, err == nil, err
 }
@@ -0,0 +1,141 @@
+package caps
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeRows отдаёт заранее заданные строки; Scan присваивает значения по порядку колонок.
+type fakeRows struct {
+	cols   []string
+	data   [][]any
+	i      int
+	closed bool
+}
+
+func (r *fakeRows) Close()                        { r.closed = true }
+func (r *fakeRows) Err() error                    { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag{} }
+func (r *fakeRows) RawValues() [][]byte           { return nil }
+func (r *fakeRows) Conn() *pgx.Conn               { return nil }
+func (r *fakeRows) Values() ([]any, error)        { return r.data[r.i-1], nil }
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	out := make([]pgconn.FieldDescription, len(r.cols))
+	for i, c := range r.cols {
+		out[i].Name = c
+	}
+	return out
+}
+
+func (r *fakeRows) Next() bool {
+	if r.i >= len(r.data) {
+		return false
+	}
+	r.i++
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.data[r.i-1][i]))
+	}
+	return nil
+}
+
+type fakeQuerier struct {
+	rows *fakeRows
+	err  error
+}
+
+func (q fakeQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return q.rows, nil
+}
+
+type item struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	Note      string    `db:"-"`
+}
+
+func TestSelect(t *testing.T) {
+	now := time.Now()
+	rows := &fakeRows{
+		cols: []string{"name", "id", "created_at"},
+		data: [][]any{{"a", int64(1), now}, {"b", int64(2), now}},
+	}
+
+	got, err := Select[item](context.Background(), fakeQuerier{rows: rows}, "select")
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	want := []item{{ID: 1, Name: "a", CreatedAt: now}, {ID: 2, Name: "b", CreatedAt: now}}
+	if !reflect.DeepEqual(got, want) || !rows.closed {
+		t.Fatalf("got %+v (closed=%v), want %+v", got, rows.closed, want)
+	}
+
+	empty, err := Select[item](context.Background(), fakeQuerier{rows: &fakeRows{cols: rows.cols}}, "select")
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Fatalf("empty result: %#v, %v", empty, err)
+	}
+
+	// колонка без поля в структуре — ошибка, а не молчаливый пропуск
+	bad := &fakeRows{cols: []string{"id", "name", "created_at", "extra"}, data: [][]any{{int64(1), "a", now, 1}}}
+	if _, err := Select[item](context.Background(), fakeQuerier{rows: bad}, "select"); err == nil {
+		t.Fatal("expected error for unmapped column")
+	}
+}
+
+func TestGet(t *testing.T) {
+	cols := []string{"id", "name", "created_at"}
+
+	v, found, err := Get[item](context.Background(), fakeQuerier{rows: &fakeRows{cols: cols, data: [][]any{{int64(7), "x", time.Time{}}}}}, "select")
+	if err != nil || !found || v.ID != 7 || v.Name != "x" {
+		t.Fatalf("get: %+v, %v, %v", v, found, err)
+	}
+
+	v, found, err = Get[item](context.Background(), fakeQuerier{rows: &fakeRows{cols: cols}}, "select")
+	if err != nil || found || v != (item{}) {
+		t.Fatalf("get missing: %+v, %v, %v", v, found, err)
+	}
+
+	boom := errors.New("boom")
+	if _, found, err := Get[item](context.Background(), fakeQuerier{err: boom}, "select"); !errors.Is(err, boom) || found {
+		t.Fatalf("get error: %v, %v", found, err)
+	}
+}
+
+type fakeTx struct{ pgx.Tx }
+
+func TestTxContext(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := TxFromContext(ctx); ok {
+		t.Fatal("empty context must not carry a tx")
+	}
+
+	tx := &fakeTx{}
+	got, ok := TxFromContext(WithTx(ctx, tx))
+	if !ok || got != tx {
+		t.Fatalf("got %v, %v", got, ok)
+	}
+}
+
+func TestReadReplicaContext(t *testing.T) {
+	ctx := context.Background()
+	if ReadReplicaAllowed(ctx) {
+		t.Fatal("replica reads must be opt-in")
+	}
+	if !ReadReplicaAllowed(WithReadReplica(ctx)) {
+		t.Fatal("WithReadReplica must allow replica reads")
+	}
+}
@@ -3,23 +3,78 @@ package config
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"strings"
 	"net/url"
-	"path/filepath"
 	"strconv"
+	"time"
 )
 
 type Config struct {
 	HTTPAddr string
 	LogLevel slog.Level
 
+	HTTPRequestID bool
+	HTTPAccessLog bool
+	HTTPRecover   bool
+
 	DatabaseURL string
 	AutoMigrate bool
+	// MigrationsCheck — что делать при старте, если схема отстала или dirty (MigrationsCheck*).
+	MigrationsCheck string
+	// MigrationsLockTimeout — сколько ждать, пока миграции выполняет другой экземпляр.
+	MigrationsLockTimeout      time.Duration
+	MigrationsStatementTimeout time.Duration
+	MigrationsTimeout          time.Duration
+	// MigrationsRollback — откатывать упавшую миграцию её down-файлом.
+	MigrationsRollback bool
+	// SchemaDriftCheck — что делать при старте, если сущности расходятся с таблицами (DriftCheck*).
+	SchemaDriftCheck string
+
+	// Databases — дополнительные именованные базы из DATABASE_URL_<NAME> (имя в нижнем регистре).
+	Databases map[string]string
+	DBPool    PoolConfig
+
+	// ReplicaURLs — реплики основной базы для чтения (DB_REPLICA_URLS через запятую).
+	ReplicaURLs          []string
+	ReplicaCheckInterval time.Duration
+
+	// DBTxMaxRetries — число повторов транзакции при ошибке сериализации или deadlock.
+	DBTxMaxRetries   int
+	DBTxRetryBackoff time.Duration
+
+	// NotesTrashRetention — срок хранения заметок в корзине; 0 отключает фоновую очистку.
+	NotesTrashRetention time.Duration
+	NotesPurgeInterval  time.Duration
+}
 
-	MigrationsPath string
+// PoolConfig — параметры пула соединений, общие для всех баз.
+type PoolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
 }
 
+const databaseURLPrefix = "DATABASE_URL_"
+
+// Режимы MIGRATIONS_CHECK.
+const (
+	MigrationsCheckFail     = "fail"
+	MigrationsCheckWarn     = "warn"
+	MigrationsCheckDegraded = "degraded"
+	MigrationsCheckOff      = "off"
+)
+
+// Режимы SCHEMA_DRIFT_CHECK.
+const (
+	DriftCheckFail = "fail"
+	DriftCheckWarn = "warn"
+	DriftCheckOff  = "off"
+)
+
 func buildDatabaseURL(user string, pass string, host string, port string, name string, sslmode string) string {
 	u := &url.URL{
 		Scheme: "postgres",
@@ -42,6 +97,9 @@ func Load() (Config, error) {
 	cfg := Config{
 		HTTPAddr: getEnv("HTTP_ADDR", ":8080"),
 		LogLevel: parseLogLevel(getEnv("LOG_LEVEL", "info")),
+		HTTPRequestID: parseBool(getEnv("HTTP_REQUEST_ID", "1")),
+		HTTPAccessLog: parseBool(getEnv("HTTP_ACCESS_LOG", "1")),
+		HTTPRecover:   parseBool(getEnv("HTTP_RECOVER", "1")),
 		DatabaseURL: buildDatabaseURL(
 			getEnv("DB_USERNAME", "miniapi"),
 			getEnv("DB_PASSWORD", "miniapi"),
@@ -51,7 +109,59 @@ func Load() (Config, error) {
                         sslmode,
 		),
 		AutoMigrate: parseBool(getEnv("AUTO_MIGRATE", "0")),
-		MigrationsPath: getEnv("MIGRATIONS_PATH", defaultMigrationsPath()),
+		MigrationsRollback: parseBool(getEnv("MIGRATIONS_ROLLBACK", "0")),
+	}
+
+	// полный DATABASE_URL важнее отдельных DB_*
+	if u := strings.TrimSpace(os.Getenv("DATABASE_URL")); u != "" {
+		cfg.DatabaseURL = u
+	}
+	if cfg.Databases, err = parseDatabases(os.Environ()); err != nil {
+		return Config{}, err
+	}
+	if cfg.DBPool, err = loadPool(); err != nil {
+		return Config{}, err
+	}
+	cfg.ReplicaURLs = splitList(os.Getenv("DB_REPLICA_URLS"))
+	if cfg.ReplicaCheckInterval, err = parseDuration("DB_REPLICA_CHECK_INTERVAL", "5s"); err != nil {
+		return Config{}, err
+	}
+	if cfg.ReplicaCheckInterval <= 0 {
+		return Config{}, fmt.Errorf("DB_REPLICA_CHECK_INTERVAL must be positive")
+	}
+
+	if cfg.MigrationsCheck, err = parseMigrationsCheck(getEnv("MIGRATIONS_CHECK", MigrationsCheckWarn)); err != nil {
+		return Config{}, err
+	}
+	if cfg.SchemaDriftCheck, err = parseDriftCheck(getEnv("SCHEMA_DRIFT_CHECK", DriftCheckWarn)); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.MigrationsLockTimeout, err = parseDuration("MIGRATIONS_LOCK_TIMEOUT", "1m"); err != nil {
+		return Config{}, err
+	}
+	if cfg.MigrationsStatementTimeout, err = parseDuration("MIGRATIONS_STATEMENT_TIMEOUT", "0s"); err != nil {
+		return Config{}, err
+	}
+	if cfg.MigrationsTimeout, err = parseDuration("MIGRATIONS_TIMEOUT", "0s"); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.DBTxMaxRetries, err = parseNonNegativeInt("DB_TX_MAX_RETRIES", "3"); err != nil {
+		return Config{}, err
+	}
+	if cfg.DBTxRetryBackoff, err = parseDuration("DB_TX_RETRY_BACKOFF", "20ms"); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.NotesTrashRetention, err = parseDuration("NOTES_TRASH_RETENTION", "720h"); err != nil {
+		return Config{}, err
+	}
+	if cfg.NotesPurgeInterval, err = parseDuration("NOTES_TRASH_PURGE_INTERVAL", "1h"); err != nil {
+		return Config{}, err
+	}
+	if cfg.NotesPurgeInterval <= 0 {
+		return Config{}, fmt.Errorf("NOTES_TRASH_PURGE_INTERVAL must be positive")
 	}
 
 	if strings.TrimSpace(cfg.HTTPAddr) == "" {
@@ -65,11 +175,74 @@ func Load() (Config, error) {
 }
 
 
-func defaultMigrationsPath() string {
-	if _, err := os.Stat("/app/migrations"); err == nil {
-		return "/app/migrations"
+func loadPool() (PoolConfig, error) {
+	var p PoolConfig
+	maxConns, err := parseNonNegativeInt("DB_MAX_CONNS", "10")
+	if err != nil {
+		return p, err
+	}
+	minConns, err := parseNonNegativeInt("DB_MIN_CONNS", "0")
+	if err != nil {
+		return p, err
+	}
+	if maxConns < 1 || minConns > maxConns || maxConns > math.MaxInt32 {
+		return p, fmt.Errorf("invalid pool size: DB_MAX_CONNS=%d must be positive and not less than DB_MIN_CONNS=%d", maxConns, minConns)
+	}
+	p.MaxConns, p.MinConns = int32(maxConns), int32(minConns)
+
+	if p.MaxConnLifetime, err = parseDuration("DB_MAX_CONN_LIFETIME", "30m"); err != nil {
+		return p, err
+	}
+	if p.MaxConnIdleTime, err = parseDuration("DB_MAX_CONN_IDLE_TIME", "5m"); err != nil {
+		return p, err
+	}
+	if p.HealthCheckPeriod, err = parseDuration("DB_HEALTH_CHECK_PERIOD", "30s"); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// parseDatabases собирает именованные базы из переменных DATABASE_URL_<NAME>.
+func parseDatabases(environ []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, kv := range environ {
+		key, val, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, databaseURLPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(key, databaseURLPrefix))
+		if !validDatabaseName(name) {
+			return nil, fmt.Errorf("invalid %s: database name must be [a-z0-9_] and not \"default\"", key)
+		}
+		if strings.TrimSpace(val) == "" {
+			return nil, fmt.Errorf("%s must not be empty", key)
+		}
+		out[name] = strings.TrimSpace(val)
+	}
+	return out, nil
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func validDatabaseName(name string) bool {
+	if name == "" || name == "default" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
 	}
-	return filepath.Clean("./migrations")
+	return true
 }
 
 func getEnv(key string, def string) string {
@@ -107,6 +280,44 @@ func validateSSLMode(v string) (string, error) {
 	}
 }
 
+func parseMigrationsCheck(v string) (string, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case MigrationsCheckFail, MigrationsCheckWarn, MigrationsCheckDegraded, MigrationsCheckOff:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid MIGRATIONS_CHECK=%q; allowed: fail|warn|degraded|off", v)
+	}
+}
+
+func parseDriftCheck(v string) (string, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case DriftCheckFail, DriftCheckWarn, DriftCheckOff:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid SCHEMA_DRIFT_CHECK=%q; allowed: fail|warn|off", v)
+	}
+}
+
+func parseDuration(key, def string) (time.Duration, error) {
+	v := getEnv(key, def)
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s=%q; expected a non-negative duration like 720h", key, v)
+	}
+	return d, nil
+}
+
+func parseNonNegativeInt(key, def string) (int, error) {
+	v := getEnv(key, def)
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s=%q; expected a non-negative integer", key, v)
+	}
+	return n, nil
+}
+
 func parseBool(v string) bool {
 	v = strings.TrimSpace(strings.ToLower(v))
 	if v == "" {
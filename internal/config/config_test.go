@@ -51,3 +51,121 @@ func TestValidateSSLMode_Rejected(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	t.Setenv("NOTES_TRASH_RETENTION", "")
+	if d, err := parseDuration("NOTES_TRASH_RETENTION", "720h"); err != nil || d.Hours() != 720 {
+		t.Fatalf("expected default 720h, got %v, %v", d, err)
+	}
+
+	t.Setenv("NOTES_TRASH_RETENTION", "0")
+	if d, err := parseDuration("NOTES_TRASH_RETENTION", "720h"); err != nil || d != 0 {
+		t.Fatalf("expected 0, got %v, %v", d, err)
+	}
+
+	for _, v := range []string{"30d", "-1h", "soon"} {
+		t.Setenv("NOTES_TRASH_RETENTION", v)
+		if _, err := parseDuration("NOTES_TRASH_RETENTION", "720h"); err == nil {
+			t.Fatalf("expected error for %q", v)
+		}
+	}
+}
+
+func TestParseNonNegativeInt(t *testing.T) {
+	t.Setenv("DB_TX_MAX_RETRIES", "")
+	if n, err := parseNonNegativeInt("DB_TX_MAX_RETRIES", "3"); err != nil || n != 3 {
+		t.Fatalf("expected default 3, got %v, %v", n, err)
+	}
+
+	t.Setenv("DB_TX_MAX_RETRIES", " 0 ")
+	if n, err := parseNonNegativeInt("DB_TX_MAX_RETRIES", "3"); err != nil || n != 0 {
+		t.Fatalf("expected 0, got %v, %v", n, err)
+	}
+
+	for _, v := range []string{"-1", "many", "1.5"} {
+		t.Setenv("DB_TX_MAX_RETRIES", v)
+		if _, err := parseNonNegativeInt("DB_TX_MAX_RETRIES", "3"); err == nil {
+			t.Fatalf("expected error for %q", v)
+		}
+	}
+}
+
+func TestParseDatabases(t *testing.T) {
+	got, err := parseDatabases([]string{
+		"PATH=/bin",
+		"DATABASE_URL=postgres://main",
+		"DATABASE_URL_ANALYTICS=postgres://analytics",
+		"DATABASE_URL_audit_log= postgres://audit ",
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(got) != 2 || got["analytics"] != "postgres://analytics" || got["audit_log"] != "postgres://audit" {
+		t.Fatalf("unexpected databases: %v", got)
+	}
+
+	for _, kv := range []string{"DATABASE_URL_=x", "DATABASE_URL_DEFAULT=x", "DATABASE_URL_A-B=x", "DATABASE_URL_X="} {
+		if _, err := parseDatabases([]string{kv}); err == nil {
+			t.Fatalf("expected error for %q", kv)
+		}
+	}
+}
+
+func TestLoadPool(t *testing.T) {
+	for _, k := range []string{"DB_MAX_CONNS", "DB_MIN_CONNS", "DB_MAX_CONN_LIFETIME", "DB_MAX_CONN_IDLE_TIME", "DB_HEALTH_CHECK_PERIOD"} {
+		t.Setenv(k, "")
+	}
+	p, err := loadPool()
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if p.MaxConns != 10 || p.MinConns != 0 || p.MaxConnLifetime.Minutes() != 30 || p.MaxConnIdleTime.Minutes() != 5 || p.HealthCheckPeriod.Seconds() != 30 {
+		t.Fatalf("unexpected defaults: %+v", p)
+	}
+
+	t.Setenv("DB_MAX_CONNS", "4")
+	t.Setenv("DB_MIN_CONNS", "5")
+	if _, err := loadPool(); err == nil {
+		t.Fatal("expected error for min > max")
+	}
+
+	t.Setenv("DB_MIN_CONNS", "0")
+	t.Setenv("DB_MAX_CONNS", "0")
+	if _, err := loadPool(); err == nil {
+		t.Fatal("expected error for zero max conns")
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" postgres://r1 ,,postgres://r2, ")
+	if len(got) != 2 || got[0] != "postgres://r1" || got[1] != "postgres://r2" {
+		t.Fatalf("unexpected list: %q", got)
+	}
+	if got := splitList(""); got != nil {
+		t.Fatalf("empty value: %q", got)
+	}
+}
+
+func TestParseMigrationsCheck(t *testing.T) {
+	for in, want := range map[string]string{"fail": MigrationsCheckFail, " Warn ": MigrationsCheckWarn, "degraded": MigrationsCheckDegraded, "off": MigrationsCheckOff} {
+		got, err := parseMigrationsCheck(in)
+		if err != nil || got != want {
+			t.Fatalf("%q: got %q, %v", in, got, err)
+		}
+	}
+	if _, err := parseMigrationsCheck("strict"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseDriftCheck(t *testing.T) {
+	for in, want := range map[string]string{"fail": DriftCheckFail, " Warn ": DriftCheckWarn, "off": DriftCheckOff} {
+		got, err := parseDriftCheck(in)
+		if err != nil || got != want {
+			t.Fatalf("%q: got %q, %v", in, got, err)
+		}
+	}
+	if _, err := parseDriftCheck("degraded"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -6,34 +6,63 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-)
 
-func Connect(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
-	cfg, err := pgxpool.ParseConfig(databaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("parse database url: %w", err)
-	}
-
-	// разумные дефолты
-	cfg.MaxConns = 10
-	cfg.MinConns = 0
-	cfg.MaxConnLifetime = 30 * time.Minute
-	cfg.MaxConnIdleTime = 5 * time.Minute
-	cfg.HealthCheckPeriod = 30 * time.Second
+	"github.com/Illusiard/miniapi/internal/config"
+)
 
-	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+func Connect(ctx context.Context, databaseURL string, pool config.PoolConfig) (*pgxpool.Pool, error) {
+	p, err := Open(ctx, databaseURL, pool)
 	if err != nil {
-		return nil, fmt.Errorf("create pool: %w", err)
+		return nil, err
 	}
 
 	// ping при старте
 	pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	if err := pool.Ping(pingCtx); err != nil {
-		pool.Close()
+	if err := p.Ping(pingCtx); err != nil {
+		p.Close()
 		return nil, fmt.Errorf("db ping: %w", err)
 	}
 
-	return pool, nil
+	return p, nil
+}
+
+// Open создаёт пул без проверки соединения: так открываются реплики,
+// недоступность которых не должна мешать старту.
+func Open(ctx context.Context, databaseURL string, pool config.PoolConfig) (*pgxpool.Pool, error) {
+	cfg, err := poolConfig(databaseURL, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create pool: %w", err)
+	}
+	return p, nil
+}
+
+// poolConfig применяет параметры пула; нулевые значения оставляют дефолты pgx
+// (кроме MinConns, у которого ноль и есть дефолт).
+func poolConfig(databaseURL string, pool config.PoolConfig) (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse database url: %w", err)
+	}
+
+	if pool.MaxConns > 0 {
+		cfg.MaxConns = pool.MaxConns
+	}
+	cfg.MinConns = pool.MinConns
+	if pool.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = pool.MaxConnLifetime
+	}
+	if pool.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = pool.MaxConnIdleTime
+	}
+	if pool.HealthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = pool.HealthCheckPeriod
+	}
+	return cfg, nil
 }
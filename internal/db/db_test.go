@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Illusiard/miniapi/internal/config"
+)
+
+func TestPoolConfig(t *testing.T) {
+	cfg, err := poolConfig("postgres://u:p@localhost:5432/db?sslmode=disable", config.PoolConfig{
+		MaxConns:          25,
+		MinConns:          2,
+		MaxConnLifetime:   time.Hour,
+		MaxConnIdleTime:   time.Minute,
+		HealthCheckPeriod: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("pool config: %v", err)
+	}
+	if cfg.MaxConns != 25 || cfg.MinConns != 2 || cfg.MaxConnLifetime != time.Hour ||
+		cfg.MaxConnIdleTime != time.Minute || cfg.HealthCheckPeriod != 10*time.Second {
+		t.Fatalf("unexpected pool config: %+v", cfg)
+	}
+
+	// нулевые значения не затирают дефолты pgx
+	def, err := poolConfig("postgres://u:p@localhost:5432/db", config.PoolConfig{})
+	if err != nil {
+		t.Fatalf("pool config: %v", err)
+	}
+	if def.MaxConns <= 0 || def.MaxConnLifetime <= 0 || def.HealthCheckPeriod <= 0 {
+		t.Fatalf("defaults lost: %+v", def)
+	}
+
+	if _, err := poolConfig("postgres://%zz", config.PoolConfig{}); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
@@ -0,0 +1,171 @@
+// Package drift сверяет сущности из meta с реальными таблицами базы.
+package drift
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+// Column — колонка таблицы из information_schema.
+type Column struct {
+	Name string `json:"name"`
+	// Type — data_type из information_schema, например "bigint" или "timestamp with time zone".
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	// Required — not null без default и не generated: вставка без этой колонки упадёт.
+	Required bool `json:"required"`
+}
+
+// Mismatch — поле сущности, которое не совпадает с колонкой по типу или nullability.
+type Mismatch struct {
+	Column         string `json:"column"`
+	FieldType      string `json:"fieldType"`
+	ColumnType     string `json:"columnType"`
+	FieldNullable  bool   `json:"fieldNullable"`
+	ColumnNullable bool   `json:"columnNullable"`
+}
+
+// Report — расхождения одной сущности с её таблицей.
+type Report struct {
+	Entity         string     `json:"entity"`
+	Module         string     `json:"module"`
+	Table          string     `json:"table"`
+	MissingTable   bool       `json:"missingTable"`
+	MissingColumns []string   `json:"missingColumns"`
+	ExtraColumns   []Column   `json:"extraColumns"`
+	Mismatches     []Mismatch `json:"mismatches"`
+}
+
+// Err сообщает, чем сущность расходится с таблицей. Лишние колонки ошибкой
+// считаются, только если они Required: сущность может описывать часть таблицы
+// (например, без служебного tsvector), но вставить строку через неё нельзя.
+func (r Report) Err() error {
+	if r.MissingTable {
+		return fmt.Errorf("%s: table %q does not exist", r.Entity, r.Table)
+	}
+	var problems []string
+	if len(r.MissingColumns) > 0 {
+		problems = append(problems, "missing columns "+strings.Join(r.MissingColumns, ", "))
+	}
+	var required []string
+	for _, c := range r.ExtraColumns {
+		if c.Required {
+			required = append(required, c.Name)
+		}
+	}
+	if len(required) > 0 {
+		problems = append(problems, "undeclared required columns "+strings.Join(required, ", "))
+	}
+	for _, m := range r.Mismatches {
+		problems = append(problems, fmt.Sprintf("column %s is %s (nullable=%t), declared %s (nullable=%t)",
+			m.Column, m.ColumnType, m.ColumnNullable, m.FieldType, m.FieldNullable))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s (table %s): %s", r.Entity, r.Table, strings.Join(problems, "; "))
+}
+
+// fieldType переводит data_type колонки в тип meta.Field; "" — тип, которого в meta нет.
+func fieldType(dataType string) string {
+	switch dataType {
+	case "smallint", "integer", "bigint":
+		return "int"
+	case "real", "double precision", "numeric":
+		return "float"
+	case "text", "character varying", "character", "uuid":
+		return "string"
+	case "boolean":
+		return "bool"
+	case "timestamp with time zone", "timestamp without time zone", "date":
+		return "datetime"
+	case "json", "jsonb":
+		return "json"
+	}
+	return ""
+}
+
+// Compare сверяет сущность с колонками её таблицы; пустой cols — таблицы нет.
+func Compare(e meta.Entity, cols []Column) Report {
+	r := Report{
+		Entity:         e.Name,
+		Module:         e.Module,
+		Table:          e.Table,
+		MissingColumns: []string{},
+		ExtraColumns:   []Column{},
+		Mismatches:     []Mismatch{},
+	}
+	if len(cols) == 0 {
+		r.MissingTable = true
+		return r
+	}
+
+	byName := make(map[string]Column, len(cols))
+	for _, c := range cols {
+		byName[c.Name] = c
+	}
+	declared := make(map[string]bool, len(e.Fields))
+	for _, f := range e.Fields {
+		declared[f.Name] = true
+		c, ok := byName[f.Name]
+		if !ok {
+			r.MissingColumns = append(r.MissingColumns, f.Name)
+			continue
+		}
+		if fieldType(c.Type) != f.Type || c.Nullable != f.Nullable {
+			r.Mismatches = append(r.Mismatches, Mismatch{
+				Column:         f.Name,
+				FieldType:      f.Type,
+				ColumnType:     c.Type,
+				FieldNullable:  f.Nullable,
+				ColumnNullable: c.Nullable,
+			})
+		}
+	}
+	for _, c := range cols {
+		if !declared[c.Name] {
+			r.ExtraColumns = append(r.ExtraColumns, c)
+		}
+	}
+	return r
+}
+
+// splitTable разделяет "schema.table" как модуль entities; без схемы — nil, текущая схема.
+func splitTable(table string) (schema *string, name string) {
+	if s, n, ok := strings.Cut(table, "."); ok {
+		return &s, n
+	}
+	return nil, table
+}
+
+// Columns читает колонки таблицы в порядке их объявления. Таблица без схемы
+// ищется в текущей схеме.
+func Columns(ctx context.Context, q caps.Querier, table string) ([]Column, error) {
+	schema, name := splitTable(table)
+	cols, err := caps.Select[Column](ctx, q, `
+select column_name as name,
+       data_type as type,
+       is_nullable = 'YES' as nullable,
+       is_nullable = 'NO' and column_default is null
+         and is_generated = 'NEVER' and is_identity = 'NO' as required
+  from information_schema.columns
+ where table_schema = coalesce($1, current_schema()) and table_name = $2
+ order by ordinal_position`, schema, name)
+	if err != nil {
+		return nil, fmt.Errorf("columns of %s: %w", table, err)
+	}
+	return cols, nil
+}
+
+// Check сверяет сущность с живой таблицей.
+func Check(ctx context.Context, q caps.Querier, e meta.Entity) (Report, error) {
+	cols, err := Columns(ctx, q, e.Table)
+	if err != nil {
+		return Report{}, err
+	}
+	return Compare(e, cols), nil
+}
@@ -0,0 +1,97 @@
+package drift
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+var note = meta.Entity{
+	Name:   "Note",
+	Table:  "notes",
+	Module: "notes",
+	Fields: []meta.Field{
+		{Name: "id", Type: "int"},
+		{Name: "title", Type: "string"},
+		{Name: "deleted_at", Type: "datetime", Nullable: true},
+	},
+}
+
+func TestCompareInSync(t *testing.T) {
+	r := Compare(note, []Column{
+		{Name: "id", Type: "bigint"},
+		{Name: "title", Type: "text", Required: true},
+		{Name: "search", Type: "tsvector", Nullable: true},
+		{Name: "deleted_at", Type: "timestamp with time zone", Nullable: true},
+	})
+	if err := r.Err(); err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+	if want := []Column{{Name: "search", Type: "tsvector", Nullable: true}}; !reflect.DeepEqual(r.ExtraColumns, want) {
+		t.Fatalf("ExtraColumns = %+v, want %+v", r.ExtraColumns, want)
+	}
+}
+
+func TestCompareMissingTable(t *testing.T) {
+	r := Compare(note, nil)
+	if !r.MissingTable || r.Err() == nil {
+		t.Fatalf("report = %+v, want missing table", r)
+	}
+}
+
+func TestCompareDrift(t *testing.T) {
+	r := Compare(note, []Column{
+		{Name: "id", Type: "uuid"},
+		{Name: "title", Type: "text", Nullable: true},
+		{Name: "owner_id", Type: "bigint", Required: true},
+	})
+
+	if want := []string{"deleted_at"}; !reflect.DeepEqual(r.MissingColumns, want) {
+		t.Fatalf("MissingColumns = %v, want %v", r.MissingColumns, want)
+	}
+	want := []Mismatch{
+		{Column: "id", FieldType: "int", ColumnType: "uuid"},
+		{Column: "title", FieldType: "string", ColumnType: "text", ColumnNullable: true},
+	}
+	if !reflect.DeepEqual(r.Mismatches, want) {
+		t.Fatalf("Mismatches = %+v, want %+v", r.Mismatches, want)
+	}
+
+	err := r.Err()
+	if err == nil {
+		t.Fatal("Err = nil, want drift")
+	}
+	for _, s := range []string{"missing columns deleted_at", "undeclared required columns owner_id", "column id is uuid"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Err = %q, want it to mention %q", err, s)
+		}
+	}
+}
+
+func TestFieldType(t *testing.T) {
+	for in, want := range map[string]string{
+		"integer":                  "int",
+		"double precision":         "float",
+		"character varying":        "string",
+		"boolean":                  "bool",
+		"timestamp with time zone": "datetime",
+		"jsonb":                    "json",
+		"json":                     "json",
+		"tsvector":                 "",
+	} {
+		if got := fieldType(in); got != want {
+			t.Errorf("fieldType(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSplitTable(t *testing.T) {
+	if schema, name := splitTable("notes"); schema != nil || name != "notes" {
+		t.Fatalf("notes: got %v, %q", schema, name)
+	}
+	if schema, name := splitTable("audit.events"); schema == nil || *schema != "audit" || name != "events" {
+		t.Fatalf("audit.events: got %v, %q", schema, name)
+	}
+}
@@ -0,0 +1,119 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newTestServer(t *testing.T, buf *bytes.Buffer) http.Handler {
+	t.Helper()
+
+	log := slog.New(NewLogHandler(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	s, err := New(":0", nil, func(r chi.Router) error {
+		r.Group(func(r chi.Router) {
+			r.Use(TagModule("demo"))
+			r.Get("/items/{id}", func(w http.ResponseWriter, req *http.Request) {
+				log.InfoContext(req.Context(), "inside handler")
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("ok"))
+			})
+			r.Get("/boom", func(w http.ResponseWriter, req *http.Request) {
+				panic("boom")
+			})
+		})
+		return nil
+	}, Options{RequestID: true, AccessLog: true, Recover: true, Logger: log})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	return s.http.Handler
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestMiddleware_RequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	h := newTestServer(t, &buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected propagated request id, got %q", got)
+	}
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected handler + access log lines, got %v", lines)
+	}
+	if lines[0]["request_id"] != "abc-123" {
+		t.Fatalf("handler log without request_id: %v", lines[0])
+	}
+
+	access := lines[1]
+	if access["msg"] != "http request" || access["route"] != "/items/{id}" || access["module"] != "demo" ||
+		access["status"] != float64(http.StatusAccepted) || access["bytes"] != float64(2) || access["request_id"] != "abc-123" {
+		t.Fatalf("unexpected access log: %v", access)
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	h := newTestServer(t, &buf)
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	got := rec.Header().Get(RequestIDHeader)
+	if len(got) != 32 {
+		t.Fatalf("expected generated 32-char id, got %q", got)
+	}
+}
+
+func TestMiddleware_Recover(t *testing.T) {
+	var buf bytes.Buffer
+	h := newTestServer(t, &buf)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	var out map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if out["error"] != "internal_error" {
+		t.Fatalf("unexpected body: %v", out)
+	}
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 || lines[0]["msg"] != "panic recovered" || lines[1]["status"] != float64(http.StatusInternalServerError) {
+		t.Fatalf("unexpected log lines: %v", lines)
+	}
+}
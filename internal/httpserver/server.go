@@ -2,7 +2,9 @@ package httpserver
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
+	"maps"
 	"net/http"
 	"time"
 
@@ -17,9 +19,23 @@ type Server struct {
 
 type ReadyFn func(ctx context.Context) error
 
-func New(addr string, ready ReadyFn, register func(r chi.Router)) *Server {
+func New(addr string, ready ReadyFn, register func(r chi.Router) error, opts Options) (*Server, error) {
 	r := chi.NewRouter()
 
+	log := opts.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	if opts.RequestID {
+		r.Use(RequestID)
+	}
+	if opts.AccessLog {
+		r.Use(AccessLog(log))
+	}
+	if opts.Recover {
+		r.Use(Recover(log))
+	}
+
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -27,27 +43,30 @@ func New(addr string, ready ReadyFn, register func(r chi.Router)) *Server {
 	})
 
 	r.Get("/ready", func(w http.ResponseWriter, r *http.Request) {
-		if ready == nil {
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte(`{"status":"ready"}`))
-			return
-		}
-
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 		defer cancel()
 
-		if err := ready(ctx); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			_, _ = w.Write([]byte(`{"status":"not_ready"}`))
-			return
+		body := map[string]any{}
+		if opts.ReadyInfo != nil {
+			maps.Copy(body, opts.ReadyInfo(ctx))
+		}
+		status, code := "ready", http.StatusOK
+		if ready != nil {
+			if err := ready(ctx); err != nil {
+				status, code = "not_ready", http.StatusServiceUnavailable
+			}
 		}
+		body["status"] = status
 
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte(`{"status":"ready"}`))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(body)
 	})
 
 	if register != nil {
-		register(r)
+		if err := register(r); err != nil {
+			return nil, err
+		}
 	}
 
 	s := &http.Server{
@@ -56,7 +75,7 @@ func New(addr string, ready ReadyFn, register func(r chi.Router)) *Server {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	return &Server{addr: addr, http: s}
+	return &Server{addr: addr, http: s}, nil
 }
 
 func (s *Server) Start(ctx context.Context) error {
@@ -0,0 +1,52 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	info := func(ctx context.Context) map[string]any {
+		return map[string]any{"replicas": map[string]any{"status": "down"}}
+	}
+	cases := []struct {
+		name   string
+		ready  ReadyFn
+		code   int
+		status string
+	}{
+		{"ready", func(ctx context.Context) error { return nil }, http.StatusOK, "ready"},
+		{"not ready", func(ctx context.Context) error { return errors.New("db down") }, http.StatusServiceUnavailable, "not_ready"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := New(":0", c.ready, nil, Options{ReadyInfo: info})
+			if err != nil {
+				t.Fatalf("new server: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			s.http.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			var body struct {
+				Status   string `json:"status"`
+				Replicas struct {
+					Status string `json:"status"`
+				} `json:"replicas"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if rec.Code != c.code || body.Status != c.status {
+				t.Fatalf("got %d %q, want %d %q", rec.Code, body.Status, c.code, c.status)
+			}
+			// недоступные реплики видны в ответе, но готовность не снимают
+			if body.Replicas.Status != "down" {
+				t.Fatalf("replicas: %q", body.Replicas.Status)
+			}
+		})
+	}
+}
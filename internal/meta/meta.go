@@ -1,27 +1,61 @@
 package meta
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrRouteConflict = errors.New("route conflict")
+
 type Field struct {
 	Name     string `json:"name"`
 	Type     string `json:"type"`
 	Nullable bool   `json:"nullable"`
 }
 
+// Виды связей между сущностями.
+const (
+	BelongsTo  = "belongs_to"
+	HasMany    = "has_many"
+	ManyToMany = "many_to_many"
+)
+
+// Relation описывает связь сущности с другой сущностью. Field — внешний ключ
+// (у belongs_to — в этой таблице, у has_many — в связанной), Through — таблица связи many_to_many.
+type Relation struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Entity  string `json:"entity"`
+	Field   string `json:"field,omitempty"`
+	Through string `json:"through,omitempty"`
+}
+
 type Entity struct {
-	Name   string  `json:"name"`
-	Table  string  `json:"table"`
-	Fields []Field `json:"fields"`
-	Module string  `json:"module"`
+	Name      string     `json:"name"`
+	Table     string     `json:"table"`
+	Fields    []Field    `json:"fields"`
+	Relations []Relation `json:"relations,omitempty"`
+	Module    string     `json:"module"`
 }
 
 type Registry struct {
 	entities []Entity
 	modules  []Module
+	routes   []Route
+
+	routeOwners map[string]string
+	mountOwners map[string]string
 }
 
 func New() *Registry {
 	return &Registry{
 		entities: make([]Entity, 0, 16),
 		modules:  make([]Module, 0, 16),
+		routes:   make([]Route, 0, 32),
+
+		routeOwners: make(map[string]string),
+		mountOwners: make(map[string]string),
 	}
 }
 
@@ -45,3 +79,53 @@ func (r *Registry) Modules() []Module {
 	copy(out, r.modules)
 	return out
 }
+
+// AddRoute отклоняет повторную регистрацию того же method+pattern, даже внутри одного модуля:
+// chi молча перезаписал бы обработчик.
+func (r *Registry) AddRoute(rt Route) error {
+	key := rt.Method + " " + rt.Pattern
+	if owner, ok := r.routeOwners[key]; ok {
+		return fmt.Errorf("%w: %s registered by %q and %q", ErrRouteConflict, key, owner, rt.Module)
+	}
+	for mount, owner := range r.mountOwners {
+		if owner != rt.Module && covers(mount, rt.Pattern) {
+			return fmt.Errorf("%w: %s registered by %q is under %s mounted by %q", ErrRouteConflict, key, rt.Module, mount, owner)
+		}
+	}
+	r.routeOwners[key] = rt.Module
+	r.routes = append(r.routes, rt)
+	return nil
+}
+
+// AddMount резервирует префикс подроутера: chi паникует при повторном Mount того же пути.
+// Префикс занимает и всё под ним, поэтому маршруты и подроутеры других модулей внутри
+// него (или подроутер вокруг них) тоже конфликт: один из них затенил бы другой.
+func (r *Registry) AddMount(pattern string, module string) error {
+	if owner, ok := r.mountOwners[pattern]; ok {
+		return fmt.Errorf("%w: %s mounted by %q and %q", ErrRouteConflict, pattern, owner, module)
+	}
+	for mount, owner := range r.mountOwners {
+		if owner != module && (covers(mount, pattern) || covers(pattern, mount)) {
+			return fmt.Errorf("%w: %s mounted by %q overlaps %s mounted by %q", ErrRouteConflict, pattern, module, mount, owner)
+		}
+	}
+	for _, rt := range r.routes {
+		if rt.Module != module && covers(pattern, rt.Pattern) {
+			return fmt.Errorf("%w: %s mounted by %q covers %s %s registered by %q",
+				ErrRouteConflict, pattern, module, rt.Method, rt.Pattern, rt.Module)
+		}
+	}
+	r.mountOwners[pattern] = module
+	return nil
+}
+
+// covers сообщает, попадает ли pattern под префикс mount: сам префикс или путь под ним.
+func covers(mount, pattern string) bool {
+	return pattern == mount || strings.HasPrefix(pattern, strings.TrimSuffix(mount, "/")+"/")
+}
+
+func (r *Registry) Routes() []Route {
+	out := make([]Route, len(r.routes))
+	copy(out, r.routes)
+	return out
+}
@@ -1,6 +1,9 @@
 package meta
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestRegistry_Entities_Copy(t *testing.T) {
 	r := New()
@@ -39,3 +42,61 @@ func TestRegistry_Modules_Copy(t *testing.T) {
 		t.Fatalf("expected registry to be immutable from outside, got %q", m2[0].Name)
 	}
 }
+
+func TestRegistry_AddRoute_Conflict(t *testing.T) {
+	r := New()
+
+	if err := r.AddRoute(Route{Method: "GET", Pattern: "/notes", Module: "notes"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.AddRoute(Route{Method: "POST", Pattern: "/notes", Module: "notes"}); err != nil {
+		t.Fatalf("unexpected error for different method: %v", err)
+	}
+
+	err := r.AddRoute(Route{Method: "GET", Pattern: "/notes", Module: "other"})
+	if !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("expected ErrRouteConflict, got %v", err)
+	}
+
+	routes := r.Routes()
+	if len(routes) != 2 || routes[0].Module != "notes" {
+		t.Fatalf("expected first registration to win, got %+v", routes)
+	}
+
+	if err := r.AddMount("/notes", "notes"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.AddMount("/notes", "other"); !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("expected mount conflict, got %v", err)
+	}
+}
+
+func TestRegistry_MountCoversPrefix(t *testing.T) {
+	r := New()
+	if err := r.AddMount("/x", "a"); err != nil {
+		t.Fatalf("mount: %v", err)
+	}
+	// маршруты и подроутеры своего модуля под префиксом — обычное дело
+	if err := r.AddRoute(Route{Method: "GET", Pattern: "/x/{id}", Module: "a"}); err != nil {
+		t.Fatalf("own route: %v", err)
+	}
+	if err := r.AddMount("/x/sub", "a"); err != nil {
+		t.Fatalf("own nested mount: %v", err)
+	}
+	for name, err := range map[string]error{
+		"route under mount":  r.AddRoute(Route{Method: "GET", Pattern: "/x/y", Module: "b"}),
+		"route at mount":     r.AddRoute(Route{Method: "POST", Pattern: "/x", Module: "b"}),
+		"mount under mount":  r.AddMount("/x/z", "b"),
+		"mount around mount": r.AddMount("/", "b"),
+	} {
+		if !errors.Is(err, ErrRouteConflict) {
+			t.Errorf("%s: expected ErrRouteConflict, got %v", name, err)
+		}
+	}
+	if err := r.AddRoute(Route{Method: "GET", Pattern: "/xy", Module: "b"}); err != nil {
+		t.Fatalf("sibling path is not under /x: %v", err)
+	}
+	if err := r.AddMount("/xy", "c"); !errors.Is(err, ErrRouteConflict) {
+		t.Fatalf("mount over another module's route: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package meta
 type Module struct {
 	Name        string `json:"name"`
 	WithStore   bool   `json:"withStore"`
+	Database    string `json:"database,omitempty"`
 	Description string `json:"description,omitempty"`
 	Version     string `json:"version,omitempty"`
 }
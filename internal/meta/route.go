@@ -0,0 +1,19 @@
+package meta
+
+type Route struct {
+	Method    string     `json:"method"`
+	Pattern   string     `json:"pattern"`
+	Module    string     `json:"module,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
+	Request   any        `json:"-"`
+	Responses []Response `json:"-"`
+}
+
+// Response описывает код ответа и пример тела (значение Go-типа, EntityRef или nil).
+type Response struct {
+	Status int
+	Body   any
+}
+
+// EntityRef ссылается на зарегистрированную сущность вместо Go-типа в описании тела.
+type EntityRef string
@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// lockKey — ключ advisory lock миграций, «miniapi!» в ASCII. Он общий для всех
+// модулей: одновременно в базе мигрирует только один экземпляр.
+const lockKey int64 = 0x6d696e6961706921
+
+// locked выполняет fn, удерживая advisory lock каждой базы из sets. Базы блокируются
+// в порядке наборов, одинаковом у всех экземпляров, поэтому взаимоблокировки нет.
+// Одна база под разными URL блокируется один раз: второй сессии пришлось бы ждать
+// первую до lockTimeout. ctx, переданный в fn, ограничен WithTimeout.
+func (r *Runner) locked(ctx context.Context, sets []Set, fn func(ctx context.Context) error) error {
+	if r.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.timeout)
+		defer cancel()
+	}
+
+	held := map[string]bool{}
+	for _, set := range sets {
+		unlock, err := r.lock(ctx, set.DatabaseURL, held)
+		if err != nil {
+			return fmt.Errorf("migrations %s: %w", set.Module, err)
+		}
+		defer unlock()
+	}
+	return fn(ctx)
+}
+
+// lock берёт сессионный advisory lock на отдельном соединении и ждёт не дольше lockTimeout.
+// held — базы, уже заблокированные этим вызовом locked; для них lock ничего не делает.
+func (r *Runner) lock(ctx context.Context, databaseURL string, held map[string]bool) (unlock func(), err error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("sql open: %w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sql conn: %w", err)
+	}
+	release := func() {
+		_ = conn.Close()
+		_ = db.Close()
+	}
+
+	// URL одной базы могут различаться параметрами, хостом, сокетом или идти через
+	// прокси, поэтому базу опознаём по идентификатору кластера и имени базы
+	var id string
+	if err := conn.QueryRowContext(ctx, `
+		select (select system_identifier from pg_control_system())::text || '/' || current_database()`).Scan(&id); err != nil {
+		release()
+		return nil, fmt.Errorf("database identity: %w", err)
+	}
+	if held[id] {
+		release()
+		return func() {}, nil
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `select pg_try_advisory_lock($1)`, lockKey).Scan(&ok); err != nil {
+		release()
+		return nil, fmt.Errorf("migration lock: %w", err)
+	}
+	if !ok {
+		slog.Info("waiting for migration lock held by another instance", "timeout", r.opts.lockTimeout)
+		lockCtx, cancel := context.WithTimeout(ctx, r.opts.lockTimeout)
+		_, err := conn.ExecContext(lockCtx, `select pg_advisory_lock($1)`, lockKey)
+		cancel()
+		if err != nil {
+			release()
+			if errors.Is(lockCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				return nil, fmt.Errorf("migration lock: another instance is still migrating after %s", r.opts.lockTimeout)
+			}
+			return nil, fmt.Errorf("migration lock: %w", err)
+		}
+	}
+
+	held[id] = true
+	return func() {
+		// закрытие соединения тоже освободило бы блокировку, но снимаем её явно
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, _ = conn.ExecContext(unlockCtx, `select pg_advisory_unlock($1)`, lockKey)
+		release()
+	}, nil
+}
@@ -1,62 +1,517 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"path/filepath"
+	"io"
+	"io/fs"
+	"log/slog"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const (
+	defaultLockTimeout   = time.Minute
+	cancelBackendTimeout = 5 * time.Second
+)
+
+// legacyTable — общая таблица версий из времён, когда все миграции лежали в migrations/.
+const legacyTable = "schema_migrations"
+
+// Set — миграции одного модуля: файлы NNN_name.up.sql/down.sql в корне FS.
+type Set struct {
+	Module      string
+	FS          fs.FS
+	DatabaseURL string
+}
+
+// Table — таблица версий модуля.
+func Table(module string) string {
+	return legacyTable + "_" + module
+}
+
 type Runner struct {
-	migrationsPath string
-	dbURL          string
+	sets []Set
+	opts options
+}
+
+type options struct {
+	lockTimeout      time.Duration
+	statementTimeout time.Duration
+	timeout          time.Duration
+	rollback         bool
+}
+
+type Option func(*options)
+
+// WithLockTimeout — сколько ждать advisory lock, пока миграции выполняет другой экземпляр.
+func WithLockTimeout(d time.Duration) Option {
+	return func(o *options) { o.lockTimeout = d }
+}
+
+// WithStatementTimeout ограничивает каждый файл миграции; 0 — без ограничения.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(o *options) { o.statementTimeout = d }
+}
+
+// WithTimeout ограничивает всю операцию вместе с ожиданием блокировки; 0 — без ограничения.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithRollback включает откат упавшей при Up миграции её down-файлом: схема
+// возвращается к предыдущей версии вместо dirty. Down-файлы должны переживать
+// частично применённый up (drop … if exists).
+func WithRollback(on bool) Option {
+	return func(o *options) { o.rollback = on }
+}
+
+// New принимает наборы в порядке модулей: набор, которому нужна схема другого
+// модуля, должен идти после него.
+func New(sets []Set, opts ...Option) *Runner {
+	o := options{lockTimeout: defaultLockTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Runner{sets: sets, opts: o}
+}
+
+// Status — состояние миграций модуля.
+type Status struct {
+	Module string `json:"module"`
+	Table  string `json:"table"`
+	// Version — текущая версия, 0 — ничего не применено.
+	Version uint `json:"version"`
+	// Latest — последняя вшитая версия.
+	Latest     uint        `json:"latest"`
+	Dirty      bool        `json:"dirty"`
+	Migrations []Migration `json:"migrations"`
+}
+
+// Состояния миграции в Status.
+const (
+	StateApplied = "applied"
+	StatePending = "pending"
+	// StateDirty — миграция, на которой упало применение.
+	StateDirty = "dirty"
+)
+
+// Migration — миграция из набора модуля.
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+}
+
+// Err сообщает, почему схема модуля не готова: dirty или есть непримененные миграции.
+// Версия новее вшитых (откат бинарника) ошибкой не считается.
+func (s Status) Err() error {
+	switch {
+	case s.Dirty:
+		return fmt.Errorf("%s: schema is dirty at version %d", s.Module, s.Version)
+	case s.Version < s.Latest:
+		return fmt.Errorf("%s: schema version %d, latest %d", s.Module, s.Version, s.Latest)
+	}
+	return nil
+}
+
+// Modules возвращает имена модулей в порядке применения.
+func (r *Runner) Modules() []string {
+	out := make([]string, 0, len(r.sets))
+	for _, set := range r.sets {
+		out = append(out, set.Module)
+	}
+	return out
+}
+
+// Up применяет наборы по очереди, каждый в свою таблицу версий, и останавливается на первой ошибке.
+// Все изменяющие операции выполняются под advisory lock каждой затронутой базы, поэтому
+// экземпляры, стартующие одновременно с AUTO_MIGRATE=1, применяют миграции по очереди.
+// Отмена ctx прерывает текущий запрос миграции.
+func (r *Runner) Up(ctx context.Context) error {
+	return r.locked(ctx, r.sets, func(ctx context.Context) error {
+		for _, set := range r.sets {
+			err := r.run(ctx, set, r.opts.rollback, func(m *migrate.Migrate, _ source.Driver) error {
+				return m.Up()
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func New(migrationsPath, dbURL string) *Runner {
-	return &Runner{
-		migrationsPath: migrationsPath,
-		dbURL:          dbURL,
+// Down откатывает n последних миграций модуля.
+func (r *Runner) Down(ctx context.Context, module string, n int) error {
+	if n < 1 {
+		return fmt.Errorf("down: steps must be positive, got %d", n)
 	}
+	return r.module(ctx, module, func(m *migrate.Migrate, _ source.Driver) error {
+		return m.Steps(-n)
+	})
 }
 
-func (r *Runner) Up() error {
-	db, err := sql.Open("pgx", r.dbURL)
+// Goto приводит схему модуля к версии version вверх или вниз.
+func (r *Runner) Goto(ctx context.Context, module string, version uint) error {
+	return r.module(ctx, module, func(m *migrate.Migrate, _ source.Driver) error {
+		return m.Migrate(version)
+	})
+}
+
+// Force записывает версию без выполнения миграций и снимает dirty; -1 — «ничего не применено».
+// Нужна после ручного исправления схемы, на которой упала миграция.
+func (r *Runner) Force(ctx context.Context, module string, version int) error {
+	if version < -1 {
+		return fmt.Errorf("force: invalid version %d", version)
+	}
+	return r.module(ctx, module, func(m *migrate.Migrate, _ source.Driver) error {
+		return m.Force(version)
+	})
+}
+
+// Status возвращает состояние всех модулей, ничего не меняя в базе: для базы
+// со старой общей schema_migrations показывает версию, которую модуль переймёт.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	out := make([]Status, 0, len(r.sets))
+	for _, set := range r.sets {
+		if err := validModule(set.Module); err != nil {
+			return nil, err
+		}
+		st, err := status(ctx, set)
+		if err != nil {
+			return nil, fmt.Errorf("migrations %s: %w", set.Module, err)
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Check возвращает ошибки всех модулей, схема которых не готова (см. Status.Err).
+func (r *Runner) Check(ctx context.Context) error {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, st := range statuses {
+		if err := st.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func status(ctx context.Context, set Set) (Status, error) {
+	st := Status{Module: set.Module, Table: Table(set.Module)}
+
+	db, err := sql.Open("pgx", set.DatabaseURL)
+	if err != nil {
+		return st, fmt.Errorf("sql open: %w", err)
+	}
+	defer db.Close()
+
+	src, err := iofs.New(set.FS, ".")
+	if err != nil {
+		return st, fmt.Errorf("migrate source: %w", err)
+	}
+	defer src.Close()
+
+	var own, legacy bool
+	err = db.QueryRowContext(ctx, `select to_regclass($1) is not null, to_regclass($2) is not null`,
+		st.Table, legacyTable).Scan(&own, &legacy)
+	if err != nil {
+		return st, fmt.Errorf("check version tables: %w", err)
+	}
+	switch {
+	case own:
+		st.Version, st.Dirty, err = readVersion(ctx, db, st.Table)
+	case legacy:
+		st.Version, st.Dirty, err = readVersion(ctx, db, legacyTable)
+		if err == nil && !hasVersion(src, st.Version) {
+			st.Version, st.Dirty = 0, false
+		}
+	}
+	if err != nil {
+		return st, err
+	}
+
+	st.Migrations, err = list(src, st.Version, st.Dirty)
+	if n := len(st.Migrations); n > 0 {
+		st.Latest = st.Migrations[n-1].Version
+	}
+	return st, err
+}
+
+// readVersion читает версию из таблицы golang-migrate; пустая таблица — версия 0.
+func readVersion(ctx context.Context, db *sql.DB, table string) (uint, bool, error) {
+	var v int64
+	var dirty bool
+	err := db.QueryRowContext(ctx, `select version, dirty from `+table+` limit 1`).Scan(&v, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read %s: %w", table, err)
+	}
+	return uint(max(v, 0)), dirty, nil
+}
+
+func hasVersion(src source.Driver, version uint) bool {
+	r, _, err := src.ReadUp(version)
+	if err != nil {
+		return false
+	}
+	_ = r.Close()
+	return true
+}
+
+func (r *Runner) module(ctx context.Context, module string, fn func(m *migrate.Migrate, src source.Driver) error) error {
+	for _, set := range r.sets {
+		if set.Module == module {
+			return r.locked(ctx, []Set{set}, func(ctx context.Context) error {
+				return r.run(ctx, set, false, fn)
+			})
+		}
+	}
+	return fmt.Errorf("module %q has no migrations", module)
+}
+
+// run открывает набор, при необходимости перенимает общую schema_migrations и
+// выполняет fn. ErrNoChange — не ошибка. rollback откатывает упавшую up-миграцию.
+func (r *Runner) run(ctx context.Context, set Set, rollback bool, fn func(m *migrate.Migrate, src source.Driver) error) error {
+	if err := validModule(set.Module); err != nil {
+		return err
+	}
+	if err := r.open(ctx, set, rollback, fn); err != nil {
+		return fmt.Errorf("migrations %s: %w", set.Module, err)
+	}
+	return nil
+}
+
+func (r *Runner) open(ctx context.Context, set Set, rollback bool, fn func(m *migrate.Migrate, src source.Driver) error) error {
+	db, err := sql.Open("pgx", set.DatabaseURL)
 	if err != nil {
 		return fmt.Errorf("sql open: %w", err)
 	}
 	defer db.Close()
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	legacy, err := legacyVersion(ctx, db, Table(set.Module))
 	if err != nil {
-		return fmt.Errorf("migrate postgres driver: %w", err)
+		return err
 	}
 
-	srcURL := "file://" + filepath.ToSlash(r.migrationsPath)
+	src, err := iofs.New(set.FS, ".")
+	if err != nil {
+		return fmt.Errorf("migrate source: %w", err)
+	}
 
-	m, err := migrate.NewWithDatabaseInstance(srcURL, "postgres", driver)
+	// отдельное соединение, чтобы знать его pid и прервать запрос при отмене ctx
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("sql conn: %w", err)
+	}
+	var pid int
+	if err := conn.QueryRowContext(ctx, `select pg_backend_pid()`).Scan(&pid); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("backend pid: %w", err)
+	}
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
+		MigrationsTable:  Table(set.Module),
+		StatementTimeout: r.opts.statementTimeout,
+	})
 	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("migrate postgres driver: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		_ = driver.Close()
 		return fmt.Errorf("migrate init: %w", err)
 	}
 	defer func() { _, _ = m.Close() }()
 
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
+	if legacy > 0 {
+		if err := adoptLegacy(db, src, m, set.Module, legacy); err != nil {
+			return err
 		}
+	}
 
-		v, dirty, verr := m.Version()
-		if verr == nil {
-			if dirty {
-				return fmt.Errorf("migrate up failed (version=%d, dirty): %w", v, err)
-			}
-			return fmt.Errorf("migrate up failed (version=%d): %w", v, err)
+	stop := interruptOnCancel(ctx, db, m, pid)
+	err = fn(m, src)
+	stop()
+
+	if err == nil || errors.Is(err, migrate.ErrNoChange) {
+		// GracefulStop останавливает migrate между миграциями без ошибки
+		if cerr := ctx.Err(); cerr != nil {
+			return fmt.Errorf("migrate interrupted: %w", cerr)
 		}
+		return nil
 	}
 
+	v, dirty, verr := m.Version()
+	if verr != nil {
+		return fmt.Errorf("migrate failed: %w", err)
+	}
+	if !dirty {
+		return fmt.Errorf("migrate failed (version=%d): %w", v, err)
+	}
+	if rollback {
+		if rerr := rollbackFailed(db, src, m, set.Module, v, r.opts.statementTimeout); rerr != nil {
+			return errors.Join(fmt.Errorf("migrate failed (version=%d, dirty): %w", v, err), rerr)
+		}
+		return fmt.Errorf("migrate failed at version %d and was rolled back: %w", v, err)
+	}
+	return fmt.Errorf("migrate failed (version=%d, dirty): %w", v, err)
+}
+
+// interruptOnCancel при отмене ctx просит migrate остановиться после текущей миграции
+// и отменяет выполняющийся запрос через pg_cancel_backend. stop снимает наблюдение.
+func interruptOnCancel(ctx context.Context, db *sql.DB, m *migrate.Migrate, pid int) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		m.GracefulStop <- true
+		cancelCtx, cancel := context.WithTimeout(context.Background(), cancelBackendTimeout)
+		defer cancel()
+		if _, err := db.ExecContext(cancelCtx, `select pg_cancel_backend($1)`, pid); err != nil {
+			slog.Warn("cancel migration query failed", "pid", pid, "error", err)
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// rollbackFailed выполняет down-файл упавшей миграции version и записывает предыдущую
+// версию. Без down-файла схема остаётся dirty.
+func rollbackFailed(db *sql.DB, src source.Driver, m *migrate.Migrate, module string, version uint, timeout time.Duration) error {
+	r, _, err := src.ReadDown(version)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no down migration for version %d, the schema stays dirty", version)
+	}
+	if err != nil {
+		return fmt.Errorf("read down migration %d: %w", version, err)
+	}
+	body, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		return fmt.Errorf("read down migration %d: %w", version, err)
+	}
+
+	// ctx операции мог быть уже отменён, а откат всё равно нужно довести до конца
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if _, err := db.ExecContext(ctx, string(body)); err != nil {
+		return fmt.Errorf("rollback of version %d failed, the schema stays dirty: %w", version, err)
+	}
+
+	prev := -1
+	if p, err := src.Prev(version); err == nil {
+		prev = int(p)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("previous version of %d: %w", version, err)
+	}
+	if err := m.Force(prev); err != nil {
+		return fmt.Errorf("set version %d after rollback: %w", prev, err)
+	}
+	slog.Warn("rolled back failed migration", "module", module, "version", version, "now", prev)
+	return nil
+}
+
+// list перечисляет миграции набора; применены все до текущей версии, а сама
+// текущая — dirty, если на ней упало применение.
+func list(src source.Driver, current uint, dirty bool) ([]Migration, error) {
+	out := []Migration{}
+	v, err := src.First()
+	for err == nil {
+		r, name, rerr := src.ReadUp(v)
+		if rerr != nil {
+			return nil, fmt.Errorf("read migration %d: %w", v, rerr)
+		}
+		_ = r.Close()
+		state := StatePending
+		switch {
+		case v == current && dirty:
+			state = StateDirty
+		case v <= current:
+			state = StateApplied
+		}
+		out = append(out, Migration{Version: v, Name: name, State: state})
+		v, err = src.Next(v)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	return out, nil
+}
+
+// legacyVersion возвращает версию из общей schema_migrations, если своей таблицы
+// у модуля ещё нет, а общая есть и чистая; иначе 0.
+func legacyVersion(ctx context.Context, db *sql.DB, table string) (uint, error) {
+	var own, legacy bool
+	err := db.QueryRowContext(ctx, `select to_regclass($1) is not null, to_regclass($2) is not null`,
+		table, legacyTable).Scan(&own, &legacy)
+	if err != nil {
+		return 0, fmt.Errorf("check version tables: %w", err)
+	}
+	if own || !legacy {
+		return 0, nil
+	}
+
+	v, dirty, err := readVersion(ctx, db, legacyTable)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%s is dirty at version %d; fix it before switching to module migrations", legacyTable, v)
+	}
+	return v, nil
+}
+
+// adoptLegacy переносит версию из общей таблицы в таблицу модуля, если такая
+// миграция есть в его наборе, и переименовывает общую в schema_migrations_legacy,
+// чтобы её не подхватил следующий модуль.
+func adoptLegacy(db *sql.DB, src source.Driver, m *migrate.Migrate, module string, version uint) error {
+	if !hasVersion(src, version) {
+		return nil
+	}
+	if err := m.Force(int(version)); err != nil {
+		return fmt.Errorf("adopt %s version %d: %w", legacyTable, version, err)
+	}
+	if _, err := db.Exec(`alter table ` + legacyTable + ` rename to ` + legacyTable + `_legacy`); err != nil {
+		return fmt.Errorf("rename %s: %w", legacyTable, err)
+	}
+	slog.Info("adopted legacy migrations", "module", module, "version", version, "table", Table(module))
+	return nil
+}
+
+func validModule(name string) error {
+	if name == "" {
+		return errors.New("migrations: empty module name")
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return fmt.Errorf("migrations: module name %q must be [a-z0-9_]", name)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidModule(t *testing.T) {
+	for _, name := range []string{"notes", "audit_log", "v2"} {
+		if err := validModule(name); err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Notes", "a-b", "x;drop"} {
+		if err := validModule(name); err == nil {
+			t.Fatalf("%q must be rejected", name)
+		}
+	}
+	if got := Table("notes"); got != "schema_migrations_notes" {
+		t.Fatalf("table = %q", got)
+	}
+}
+
+func TestStatusErr(t *testing.T) {
+	cases := []struct {
+		st   Status
+		fail bool
+	}{
+		{Status{Module: "notes", Version: 5, Latest: 5}, false},
+		{Status{Module: "notes", Version: 7, Latest: 5}, false},
+		{Status{Module: "notes", Version: 0, Latest: 5}, true},
+		{Status{Module: "notes", Version: 5, Latest: 5, Dirty: true}, true},
+	}
+	for _, c := range cases {
+		if err := c.st.Err(); (err != nil) != c.fail {
+			t.Fatalf("%+v: err = %v", c.st, err)
+		}
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	r := New(nil)
+	if r.opts.lockTimeout != defaultLockTimeout || r.opts.rollback || r.opts.timeout != 0 {
+		t.Fatalf("defaults: %+v", r.opts)
+	}
+
+	r = New(nil, WithLockTimeout(time.Second), WithStatementTimeout(2*time.Second), WithTimeout(time.Minute), WithRollback(true))
+	want := options{lockTimeout: time.Second, statementTimeout: 2 * time.Second, timeout: time.Minute, rollback: true}
+	if r.opts != want {
+		t.Fatalf("got %+v, want %+v", r.opts, want)
+	}
+}
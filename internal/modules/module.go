@@ -1,15 +1,36 @@
 package modules
 
-import "github.com/Illusiard/miniapi/internal/caps"
+import (
+	"context"
+	"io/fs"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
 
 type Module interface {
 	Name() string
 	Register(s caps.Setup) error
 }
 
+// Runner — необязательная фоновая работа модуля. App запускает Run после старта
+// HTTP-сервера и отменяет ctx при остановке.
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+// Migrator — необязательная схема модуля: FS с файлами NNN_name.up.sql/down.sql
+// в корне (обычно embed.FS). Миграции применяются в базу модуля (Spec.Database)
+// в порядке модулей, каждый набор — в свою таблицу schema_migrations_<name>.
+type Migrator interface {
+	Migrations() fs.FS
+}
+
 type Spec struct {
-	Module      Module
-	WithStore   bool
+	Module    Module
+	WithStore bool
+	// Database — имя базы из DATABASE_URL_<NAME>, которую модуль получает в Setup.Store;
+	// пусто — основная база. Требует WithStore.
+	Database    string
 	Description string
 	Version     string
 }
@@ -0,0 +1,337 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+const Version = "3.1.0"
+
+const errorSchema = "Error"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem индексируется HTTP-методом в нижнем регистре ("get", "post", ...).
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	OperationID string              `json:"operationId,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]Schema `json:"schemas"`
+}
+
+// Schema — JSON Schema (draft 2020-12), как того требует OpenAPI 3.1.
+type Schema map[string]any
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+func Build(info Info, entities []meta.Entity, routes []meta.Route) Document {
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: map[string]Schema{
+				errorSchema: {
+					"type": "object",
+					"properties": map[string]any{
+						"error": Schema{"type": "string"},
+						"field": Schema{"type": "string"},
+					},
+					"required": []string{"error"},
+				},
+			},
+		},
+	}
+
+	for _, e := range entities {
+		doc.Components.Schemas[e.Name] = entitySchema(e)
+	}
+
+	for _, rt := range routes {
+		path := pathParam.ReplaceAllString(rt.Pattern, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = operation(rt, path)
+	}
+
+	return doc
+}
+
+func operation(rt meta.Route, path string) *Operation {
+	op := &Operation{
+		Summary:     rt.Summary,
+		OperationID: operationID(rt.Method, path),
+		Responses:   make(map[string]Response),
+	}
+	if rt.Module != "" {
+		op.Tags = []string{rt.Module}
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   Schema{"type": "string"},
+		})
+	}
+
+	if rt.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(SchemaOf(rt.Request)),
+		}
+	}
+
+	for _, resp := range rt.Responses {
+		r := Response{Description: http.StatusText(resp.Status)}
+		switch {
+		case resp.Body != nil:
+			r.Content = jsonContent(SchemaOf(resp.Body))
+		case resp.Status >= 400:
+			r.Content = jsonContent(ref(errorSchema))
+		}
+		op.Responses[strconv.Itoa(resp.Status)] = r
+	}
+	if len(rt.Responses) == 0 {
+		op.Responses["200"] = Response{Description: http.StatusText(http.StatusOK)}
+	}
+	op.Responses["default"] = Response{
+		Description: "Error",
+		Content:     jsonContent(ref(errorSchema)),
+	}
+
+	return op
+}
+
+func operationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, seg := range strings.Split(path, "/") {
+		seg = strings.Trim(seg, "{}")
+		if seg == "" {
+			continue
+		}
+		parts = append(parts, strings.NewReplacer(".", "_", "-", "_").Replace(seg))
+	}
+	return strings.Join(parts, "_")
+}
+
+func jsonContent(s Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+func entitySchema(e meta.Entity) Schema {
+	props := make(map[string]any, len(e.Fields))
+	required := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		s := fieldSchema(f.Type)
+		if f.Nullable {
+			s = nullable(s)
+		}
+		props[f.Name] = s
+		required = append(required, f.Name)
+	}
+
+	s := Schema{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+	if e.Table != "" {
+		s["description"] = "Table " + e.Table
+	}
+	return s
+}
+
+func fieldSchema(typ string) Schema {
+	switch typ {
+	case "int":
+		return Schema{"type": "integer", "format": "int64"}
+	case "float":
+		return Schema{"type": "number"}
+	case "string":
+		return Schema{"type": "string"}
+	case "bool":
+		return Schema{"type": "boolean"}
+	case "datetime":
+		return Schema{"type": "string", "format": "date-time"}
+	default:
+		return Schema{}
+	}
+}
+
+// SchemaOf строит схему по значению: meta.EntityRef и []meta.EntityRef превращаются
+// в ссылки на компоненты, остальные значения описываются через reflect.
+func SchemaOf(v any) Schema {
+	switch b := v.(type) {
+	case meta.EntityRef:
+		return ref(string(b))
+	case []meta.EntityRef:
+		if len(b) == 0 {
+			return Schema{"type": "array"}
+		}
+		return Schema{"type": "array", "items": ref(string(b[0]))}
+	}
+	return typeSchema(reflect.TypeOf(v), map[reflect.Type]bool{})
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case rawType:
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(typeSchema(t.Elem(), seen))
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return Schema{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		props := map[string]any{}
+		var required []string
+		structFields(t, seen, props, &required)
+
+		s := Schema{"type": "object", "properties": props}
+		if len(required) > 0 {
+			sort.Strings(required)
+			s["required"] = required
+		}
+		return s
+	default:
+		return Schema{}
+	}
+}
+
+func structFields(t reflect.Type, seen map[reflect.Type]bool, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				structFields(ft, seen, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = typeSchema(f.Type, seen)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func nullable(s Schema) Schema {
+	if typ, ok := s["type"].(string); ok {
+		out := make(Schema, len(s))
+		for k, v := range s {
+			out[k] = v
+		}
+		out["type"] = []string{typ, "null"}
+		return out
+	}
+	if len(s) == 0 {
+		return s
+	}
+	return Schema{"anyOf": []any{s, Schema{"type": "null"}}}
+}
@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+type item struct {
+	ID      int64     `json:"id"`
+	Title   string    `json:"title"`
+	Note    *string   `json:"note,omitempty"`
+	At      time.Time `json:"at"`
+	Tags    []string  `json:"tags"`
+	private string
+	Skip    string     `json:"-"`
+	Deleted *time.Time `json:"deletedAt"`
+}
+
+func TestBuild_PathsAndParameters(t *testing.T) {
+	doc := Build(Info{Title: "t", Version: "1"}, nil, []meta.Route{
+		{Method: http.MethodGet, Pattern: "/items/{id:[0-9]+}", Module: "items", Summary: "Get item",
+			Responses: []meta.Response{{Status: http.StatusOK, Body: item{}}, {Status: http.StatusNotFound}}},
+		{Method: http.MethodDelete, Pattern: "/items/{id}", Module: "items",
+			Responses: []meta.Response{{Status: http.StatusNoContent}}},
+	})
+
+	if doc.OpenAPI != Version {
+		t.Fatalf("openapi version: got %q", doc.OpenAPI)
+	}
+	pi, ok := doc.Paths["/items/{id}"]
+	if !ok {
+		t.Fatalf("expected normalized path, got %v", doc.Paths)
+	}
+
+	get := pi["get"]
+	if get == nil || pi["delete"] == nil {
+		t.Fatalf("expected get and delete operations, got %v", pi)
+	}
+	if get.OperationID != "get_items_id" || get.Summary != "Get item" || get.Tags[0] != "items" {
+		t.Fatalf("unexpected operation: %+v", get)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Fatalf("unexpected parameters: %+v", get.Parameters)
+	}
+
+	nf := get.Responses["404"].Content["application/json"].Schema
+	if nf["$ref"] != "#/components/schemas/Error" {
+		t.Fatalf("expected 404 to reference Error, got %v", nf)
+	}
+	if _, ok := get.Responses["default"]; !ok {
+		t.Fatalf("expected default error response")
+	}
+	if len(pi["delete"].Responses["204"].Content) != 0 {
+		t.Fatalf("expected empty 204 body")
+	}
+}
+
+func TestBuild_EntitySchema(t *testing.T) {
+	doc := Build(Info{}, []meta.Entity{{
+		Name:  "Note",
+		Table: "notes",
+		Fields: []meta.Field{
+			{Name: "id", Type: "int"},
+			{Name: "summary", Type: "string", Nullable: true},
+		},
+	}}, nil)
+
+	s := doc.Components.Schemas["Note"]
+	props := s["properties"].(map[string]any)
+	if got := props["summary"].(Schema)["type"]; !reflect.DeepEqual(got, []string{"string", "null"}) {
+		t.Fatalf("nullable field type: got %v", got)
+	}
+	if got := props["id"].(Schema)["format"]; got != "int64" {
+		t.Fatalf("int field format: got %v", got)
+	}
+}
+
+func TestSchemaOf_Struct(t *testing.T) {
+	s := SchemaOf(item{})
+	props := s["properties"].(map[string]any)
+
+	if _, ok := props["Skip"]; ok {
+		t.Fatalf("json:\"-\" field must be skipped")
+	}
+	if _, ok := props["private"]; ok {
+		t.Fatalf("unexported field must be skipped")
+	}
+	if got := props["at"].(Schema)["format"]; got != "date-time" {
+		t.Fatalf("time format: got %v", got)
+	}
+	if got := props["deletedAt"].(Schema)["type"]; !reflect.DeepEqual(got, []string{"string", "null"}) {
+		t.Fatalf("pointer type: got %v", got)
+	}
+	want := []string{"at", "deletedAt", "id", "tags", "title"}
+	if !reflect.DeepEqual(s["required"], want) {
+		t.Fatalf("required: got %v want %v", s["required"], want)
+	}
+
+	arr := SchemaOf([]meta.EntityRef{"Note"})
+	if arr["items"].(Schema)["$ref"] != "#/components/schemas/Note" {
+		t.Fatalf("entity ref array: got %v", arr)
+	}
+}
@@ -2,35 +2,199 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 20 * time.Millisecond
+	maxRetryBackoff     = time.Second
+)
+
+// Коды SQLSTATE, при которых транзакцию можно безопасно повторить целиком.
+const (
+	sqlSerializationFailure = "40001"
+	sqlDeadlockDetected     = "40P01"
 )
 
 type PGStore struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	replicas replicaSet
+	opts     options
+	// txs — открытые этим хранилищем транзакции и savepoint'ы, ещё не завершённые.
+	txs sync.Map
 }
 
-func New(pool *pgxpool.Pool) *PGStore {
-	return &PGStore{pool: pool}
+type options struct {
+	maxRetries   int
+	retryBackoff time.Duration
+	log          *slog.Logger
+	replicas     []*replica
+}
+
+type Option func(*options)
+
+// WithMaxRetries — сколько раз повторять транзакцию после ошибки сериализации
+// или deadlock; 0 отключает повторы.
+func WithMaxRetries(n int) Option {
+	return func(o *options) { o.maxRetries = max(n, 0) }
+}
+
+// WithRetryBackoff — задержка перед первым повтором, дальше она удваивается (не больше секунды).
+func WithRetryBackoff(d time.Duration) Option {
+	return func(o *options) { o.retryBackoff = d }
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) { o.log = l }
+}
+
+func New(pool *pgxpool.Pool, opts ...Option) *PGStore {
+	o := options{maxRetries: defaultMaxRetries, retryBackoff: defaultRetryBackoff, log: slog.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &PGStore{pool: pool, replicas: replicaSet{list: o.replicas}, opts: o}
 }
 
 func (s *PGStore) Ping(ctx context.Context) error {
 	return s.pool.Ping(ctx)
 }
 
+// dbtx — общее у пула и транзакции.
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn возвращает транзакцию из ctx, а без неё — пул.
+func (s *PGStore) conn(ctx context.Context) dbtx {
+	if tx, ok := s.ctxTx(ctx); ok {
+		return tx
+	}
+	return s.pool
+}
+
+// ctxTx возвращает транзакцию из ctx, только если её открыло это хранилище:
+// обработчик в транзакции одной базы может вызвать Store другой базы, и её
+// запросы не должны уйти в чужую транзакцию.
+func (s *PGStore) ctxTx(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := caps.TxFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	_, own := s.txs.Load(tx)
+	return tx, own
+}
+
+// track отмечает tx как открытую этим хранилищем до вызова возвращённой функции.
+func (s *PGStore) track(tx pgx.Tx) (untrack func()) {
+	s.txs.Store(tx, struct{}{})
+	return func() { s.txs.Delete(tx) }
+}
+
 func (s *PGStore) Exec(ctx context.Context, sql string, args ...any) error {
-	_, err := s.pool.Exec(ctx, sql, args...)
+	_, err := s.conn(ctx).Exec(ctx, sql, args...)
 	return err
 }
 
+func (s *PGStore) ExecResult(ctx context.Context, sql string, args ...any) (int64, error) {
+	tag, err := s.conn(ctx).Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (s *PGStore) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if s.readsReplica(ctx) {
+		return s.queryReplica(ctx, sql, args...)
+	}
+	return s.conn(ctx).Query(ctx, sql, args...)
+}
+
+func (s *PGStore) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	if s.readsReplica(ctx) {
+		rows, err := s.queryReplica(ctx, sql, args...)
+		return rowsRow{rows: rows, err: err}
+	}
+	return s.conn(ctx).QueryRow(ctx, sql, args...)
+}
+
+// readsReplica — запрос вне транзакции, помеченный caps.WithReadReplica, при настроенных репликах.
+func (s *PGStore) readsReplica(ctx context.Context) bool {
+	if len(s.replicas.list) == 0 || !caps.ReadReplicaAllowed(ctx) {
+		return false
+	}
+	_, inTx := s.ctxTx(ctx)
+	return !inTx
+}
+
 func (s *PGStore) RunInTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
-	tx, err := s.pool.Begin(ctx)
+	return s.RunInTxOpts(ctx, pgx.TxOptions{}, fn)
+}
+
+func (s *PGStore) RunInReadTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return s.RunInTxOpts(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, fn)
+}
+
+// RunInTxOpts выполняет fn в транзакции с заданными уровнем изоляции, режимом доступа
+// и deferrable. При ошибке сериализации (40001) или deadlock (40P01) транзакция
+// откатывается и fn вызывается заново, поэтому fn не должна иметь побочных эффектов вне tx.
+// Если ctx несёт транзакцию этого хранилища, fn выполняется в SAVEPOINT внутри неё,
+// без повторов; транзакция другого хранилища не учитывается.
+// Транзакции READ ONLY идут в реплику, если она есть и доступна.
+func (s *PGStore) RunInTxOpts(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	if outer, ok := s.ctxTx(ctx); ok {
+		return s.runSavepoint(ctx, outer, fn)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := s.runTx(ctx, opts, fn)
+		code := retryableCode(err)
+		if code == "" || attempt >= s.opts.maxRetries {
+			return err
+		}
+
+		delay := retryDelay(s.opts.retryBackoff, attempt)
+		s.opts.log.WarnContext(ctx, "retrying transaction",
+			"attempt", attempt+1, "max_retries", s.opts.maxRetries, "sqlstate", code, "delay", delay, "error", err)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (s *PGStore) runTx(ctx context.Context, opts pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	var tx pgx.Tx
+	var err error
+	if opts.AccessMode == pgx.ReadOnly && len(s.replicas.list) > 0 {
+		tx, err = s.beginReplica(ctx, opts)
+	} else {
+		tx, err = s.pool.BeginTx(ctx, opts)
+	}
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
+	defer s.track(tx)()
 
 	if err := fn(tx); err != nil {
 		return err
@@ -40,3 +204,49 @@ func (s *PGStore) RunInTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
 	}
 	return nil
 }
+
+// runSavepoint выполняет fn во вложенной транзакции pgx (SAVEPOINT). При ошибке
+// откатывается только savepoint, а решение о внешней транзакции остаётся за вызывающим.
+func (s *PGStore) runSavepoint(ctx context.Context, outer pgx.Tx, fn func(tx pgx.Tx) error) error {
+	sp, err := outer.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	defer func() { _ = sp.Rollback(ctx) }()
+	defer s.track(sp)()
+
+	if err := fn(sp); err != nil {
+		return err
+	}
+	if err := sp.Commit(ctx); err != nil {
+		return fmt.Errorf("release savepoint: %w", err)
+	}
+	return nil
+}
+
+// retryableCode возвращает SQLSTATE, если транзакцию стоит повторить, иначе "".
+func retryableCode(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	switch pgErr.Code {
+	case sqlSerializationFailure, sqlDeadlockDetected:
+		return pgErr.Code
+	}
+	return ""
+}
+
+// retryDelay — экспоненциальная задержка со случайной половиной, чтобы
+// конкурирующие транзакции не повторялись синхронно.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << min(attempt, 16)
+	if d > maxRetryBackoff || d <= 0 {
+		d = maxRetryBackoff
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+func TestRetryableCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{errors.New("boom"), ""},
+		{&pgconn.PgError{Code: "23505"}, ""},
+		{&pgconn.PgError{Code: sqlSerializationFailure}, sqlSerializationFailure},
+		{fmt.Errorf("commit tx: %w", &pgconn.PgError{Code: sqlDeadlockDetected}), sqlDeadlockDetected},
+	}
+	for _, c := range cases {
+		if got := retryableCode(c.err); got != c.want {
+			t.Fatalf("retryableCode(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := 20 * time.Millisecond
+	for attempt := 0; attempt < 40; attempt++ {
+		want := min(base<<min(attempt, 16), maxRetryBackoff)
+		d := retryDelay(base, attempt)
+		if d < want/2 || d > want {
+			t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, d, want/2, want)
+		}
+	}
+	if d := retryDelay(0, 3); d != 0 {
+		t.Fatalf("zero base: got %v", d)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	s := New(nil)
+	if s.opts.maxRetries != defaultMaxRetries || s.opts.retryBackoff != defaultRetryBackoff || s.opts.log == nil {
+		t.Fatalf("unexpected defaults: %+v", s.opts)
+	}
+	s = New(nil, WithMaxRetries(-1), WithRetryBackoff(time.Second))
+	if s.opts.maxRetries != 0 || s.opts.retryBackoff != time.Second {
+		t.Fatalf("unexpected options: %+v", s.opts)
+	}
+}
+
+type fakeTx struct{ pgx.Tx }
+
+func TestCtxTxOwnedByStore(t *testing.T) {
+	a, b := New(nil), New(nil)
+	tx := &fakeTx{}
+	untrack := a.track(tx)
+	ctx := caps.WithTx(context.Background(), tx)
+
+	if got, ok := a.ctxTx(ctx); !ok || got != tx {
+		t.Fatalf("owner store: got %v, %v", got, ok)
+	}
+	if _, ok := b.ctxTx(ctx); ok {
+		t.Fatal("other store must ignore a foreign tx")
+	}
+	untrack()
+	if _, ok := a.ctxTx(ctx); ok {
+		t.Fatal("finished tx must not be used")
+	}
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const replicaPingTimeout = 2 * time.Second
+
+// replica — пул реплики и результат последней проверки. До первой проверки
+// реплика считается здоровой.
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+// replicaSet выбирает здоровые реплики по кругу.
+type replicaSet struct {
+	list []*replica
+	next atomic.Uint64
+}
+
+// ReplicaStatus — состояние реплики для /ready.
+type ReplicaStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+// WithReplicas добавляет пулы реплик. Имена в отчётах — replica-1, replica-2, …
+func WithReplicas(pools ...*pgxpool.Pool) Option {
+	return func(o *options) {
+		for _, p := range pools {
+			r := &replica{name: fmt.Sprintf("replica-%d", len(o.replicas)+1), pool: p}
+			r.healthy.Store(true)
+			o.replicas = append(o.replicas, r)
+		}
+	}
+}
+
+// pick возвращает следующую здоровую реплику или nil, если таких нет.
+func (rs *replicaSet) pick() *replica {
+	n := uint64(len(rs.list))
+	if n == 0 {
+		return nil
+	}
+	start := rs.next.Add(1) - 1
+	for i := range n {
+		if r := rs.list[(start+i)%n]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// Replicas возвращает состояние реплик по последней проверке.
+func (s *PGStore) Replicas() []ReplicaStatus {
+	out := make([]ReplicaStatus, 0, len(s.replicas.list))
+	for _, r := range s.replicas.list {
+		out = append(out, ReplicaStatus{Name: r.name, Healthy: r.healthy.Load()})
+	}
+	return out
+}
+
+// CheckReplicas пингует все реплики и обновляет их состояние.
+func (s *PGStore) CheckReplicas(ctx context.Context) []ReplicaStatus {
+	for _, r := range s.replicas.list {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := r.pool.Ping(pingCtx)
+		cancel()
+		if err != nil && ctx.Err() != nil {
+			// отменили саму проверку, а не реплика упала
+			break
+		}
+		s.setHealthy(ctx, r, err)
+	}
+	return s.Replicas()
+}
+
+// RunReplicaChecks проверяет реплики каждые interval до отмены ctx.
+func (s *PGStore) RunReplicaChecks(ctx context.Context, interval time.Duration) {
+	if len(s.replicas.list) == 0 || interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		s.CheckReplicas(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *PGStore) setHealthy(ctx context.Context, r *replica, err error) {
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		s.opts.log.InfoContext(ctx, "replica is back", "replica", r.name)
+	} else {
+		s.opts.log.WarnContext(ctx, "replica is down, reading from primary", "replica", r.name, "error", err)
+	}
+}
+
+// connFailed отличает недоступность реплики от ошибки самого запроса:
+// ответ сервера с SQLSTATE и отмена ctx реплику не выключают.
+func connFailed(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	return !errors.As(err, &pgErr)
+}
+
+// queryReplica выполняет запрос на реплике; если она недоступна, помечает её
+// и повторяет запрос на следующей реплике или в основной базе.
+func (s *PGStore) queryReplica(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	for range s.replicas.list {
+		r := s.replicas.pick()
+		if r == nil {
+			break
+		}
+		rows, err := r.pool.Query(ctx, sql, args...)
+		if !connFailed(ctx, err) {
+			return rows, err
+		}
+		s.setHealthy(ctx, r, err)
+	}
+	return s.pool.Query(ctx, sql, args...)
+}
+
+// beginReplica открывает транзакцию на реплике, а если ни одна не доступна — в основной базе.
+func (s *PGStore) beginReplica(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	for range s.replicas.list {
+		r := s.replicas.pick()
+		if r == nil {
+			break
+		}
+		tx, err := r.pool.BeginTx(ctx, opts)
+		if !connFailed(ctx, err) {
+			return tx, err
+		}
+		s.setHealthy(ctx, r, err)
+	}
+	return s.pool.BeginTx(ctx, opts)
+}
+
+// rowsRow — pgx.Row поверх pgx.Rows, как делает сам pgx в QueryRow.
+type rowsRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r rowsRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
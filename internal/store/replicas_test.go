@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func newReplicaSet(healthy ...bool) *replicaSet {
+	rs := &replicaSet{}
+	for i, h := range healthy {
+		r := &replica{name: fmt.Sprintf("replica-%d", i+1)}
+		r.healthy.Store(h)
+		rs.list = append(rs.list, r)
+	}
+	return rs
+}
+
+func TestReplicaPick(t *testing.T) {
+	if r := newReplicaSet().pick(); r != nil {
+		t.Fatalf("no replicas: got %s", r.name)
+	}
+
+	rs := newReplicaSet(true, false, true)
+	var got []string
+	for range 4 {
+		got = append(got, rs.pick().name)
+	}
+	want := []string{"replica-1", "replica-3", "replica-3", "replica-1"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("round-robin: got %v, want %v", got, want)
+	}
+
+	if r := newReplicaSet(false, false).pick(); r != nil {
+		t.Fatalf("all down: got %s", r.name)
+	}
+}
+
+func TestConnFailed(t *testing.T) {
+	ctx := context.Background()
+	if connFailed(ctx, nil) {
+		t.Fatal("nil error")
+	}
+	if connFailed(ctx, fmt.Errorf("query: %w", &pgconn.PgError{Code: "42P01"})) {
+		t.Fatal("server error must not disable the replica")
+	}
+	if !connFailed(ctx, errors.New("dial tcp: connection refused")) {
+		t.Fatal("network error must disable the replica")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if connFailed(canceled, context.Canceled) {
+		t.Fatal("canceled context must not disable the replica")
+	}
+}
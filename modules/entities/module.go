@@ -0,0 +1,411 @@
+package entities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+const idField = "id"
+
+type Module struct {
+	defs []meta.Entity
+}
+
+// New принимает дополнительные объявления сущностей, которые публикуются от имени модуля.
+// Маршруты монтируются для всех сущностей с таблицей, уже зарегистрированных в Meta,
+// кроме сущностей модулей со своими маршрутами, поэтому модуль должен регистрироваться последним.
+func New(defs ...meta.Entity) *Module { return &Module{defs: defs} }
+
+func (m *Module) Name() string { return "entities" }
+
+func (m *Module) Register(s caps.Setup) error {
+	if s.Store == nil {
+		return errConfig("entities module requires Store capability")
+	}
+
+	for _, e := range m.defs {
+		if e.Module == "" {
+			e.Module = m.Name()
+		}
+		s.Meta.AddEntity(e)
+	}
+
+	// Сущности модуля со своими маршрутами обслуживает сам модуль: общий CRUD
+	// обошёл бы его правила (мягкое удаление, If-Match, история правок).
+	served := map[string]bool{}
+	for _, rt := range s.Meta.Routes() {
+		served[rt.Module] = true
+	}
+
+	var tables []table
+	for _, e := range s.Meta.Entities() {
+		if e.Table == "" || served[e.Module] {
+			continue
+		}
+		t, err := newTable(e)
+		if err != nil {
+			return fmt.Errorf("entity %s: %w", e.Name, err)
+		}
+		tables = append(tables, t)
+	}
+
+	s.Routes.Route("/entities", func(r caps.Routes) {
+		for _, t := range tables {
+			ref := meta.EntityRef(t.entity.Name)
+			r.Route("/"+t.entity.Table, func(r caps.Routes) {
+				r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+					items, err := t.list(req.Context(), s.Store)
+					if err != nil {
+						writeError(w, http.StatusInternalServerError, "list_failed")
+						return
+					}
+					writeJSON(w, http.StatusOK, items)
+				}, caps.Summary("List "+t.entity.Name+" (max 100)"), caps.Returns(http.StatusOK, []meta.EntityRef{ref}),
+					caps.Returns(http.StatusInternalServerError, nil))
+
+				r.Post("/", func(w http.ResponseWriter, req *http.Request) {
+					values, ok := t.decodeBody(w, req, true)
+					if !ok {
+						return
+					}
+					item, err := t.create(req.Context(), s.Store, values)
+					if err != nil {
+						writeStoreError(w, err, "create_failed")
+						return
+					}
+					writeJSON(w, http.StatusCreated, item)
+				}, caps.Summary("Create "+t.entity.Name), caps.Accepts(ref), caps.Returns(http.StatusCreated, ref),
+					caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusConflict, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+				if !t.hasID {
+					return
+				}
+
+				r.Get("/{id}", func(w http.ResponseWriter, req *http.Request) {
+					id, ok := t.parseID(w, req)
+					if !ok {
+						return
+					}
+					item, found, err := t.get(req.Context(), s.Store, id)
+					if err != nil {
+						writeError(w, http.StatusInternalServerError, "get_failed")
+						return
+					}
+					if !found {
+						writeError(w, http.StatusNotFound, "not_found")
+						return
+					}
+					writeJSON(w, http.StatusOK, item)
+				}, caps.Summary("Get "+t.entity.Name), caps.Returns(http.StatusOK, ref),
+					caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+				r.Put("/{id}", func(w http.ResponseWriter, req *http.Request) {
+					id, ok := t.parseID(w, req)
+					if !ok {
+						return
+					}
+					values, ok := t.decodeBody(w, req, false)
+					if !ok {
+						return
+					}
+					if len(values) == 0 {
+						writeError(w, http.StatusBadRequest, "no_fields")
+						return
+					}
+					item, found, err := t.update(req.Context(), s.Store, id, values)
+					if err != nil {
+						writeStoreError(w, err, "update_failed")
+						return
+					}
+					if !found {
+						writeError(w, http.StatusNotFound, "not_found")
+						return
+					}
+					writeJSON(w, http.StatusOK, item)
+				}, caps.Summary("Update "+t.entity.Name), caps.Accepts(ref), caps.Returns(http.StatusOK, ref),
+					caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusConflict, nil),
+					caps.Returns(http.StatusInternalServerError, nil))
+
+				r.Delete("/{id}", func(w http.ResponseWriter, req *http.Request) {
+					id, ok := t.parseID(w, req)
+					if !ok {
+						return
+					}
+					found, err := t.delete(req.Context(), s.Store, id)
+					if err != nil {
+						writeStoreError(w, err, "delete_failed")
+						return
+					}
+					if !found {
+						writeError(w, http.StatusNotFound, "not_found")
+						return
+					}
+					w.WriteHeader(http.StatusNoContent)
+				}, caps.Summary("Delete "+t.entity.Name), caps.Returns(http.StatusNoContent, nil),
+					caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusConflict, nil),
+					caps.Returns(http.StatusInternalServerError, nil))
+			})
+		}
+	})
+
+	return nil
+}
+
+type table struct {
+	entity  meta.Entity
+	fields  map[string]meta.Field
+	ident   string
+	columns string
+	hasID   bool
+}
+
+func newTable(e meta.Entity) (table, error) {
+	t := table{
+		entity: e,
+		fields: make(map[string]meta.Field, len(e.Fields)),
+		ident:  pgx.Identifier(strings.Split(e.Table, ".")).Sanitize(),
+	}
+	if len(e.Fields) == 0 {
+		return table{}, errors.New("no fields declared")
+	}
+
+	cols := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		if f.Name == "" {
+			return table{}, errors.New("field with empty name")
+		}
+		if _, dup := t.fields[f.Name]; dup {
+			return table{}, fmt.Errorf("duplicate field %q", f.Name)
+		}
+		t.fields[f.Name] = f
+		cols = append(cols, pgx.Identifier{f.Name}.Sanitize())
+	}
+	t.columns = strings.Join(cols, ", ")
+	_, t.hasID = t.fields[idField]
+
+	return t, nil
+}
+
+func (t table) parseID(w http.ResponseWriter, req *http.Request) (any, bool) {
+	idStr := chi.URLParam(req, "id")
+	if idStr == "" {
+		writeError(w, http.StatusBadRequest, "id_required")
+		return nil, false
+	}
+	if t.fields[idField].Type != "int" {
+		return idStr, true
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid_id")
+		return nil, false
+	}
+	return id, true
+}
+
+// decodeBody разбирает JSON-объект и приводит значения к типам полей сущности.
+// Поле id можно передать только при создании.
+func (t table) decodeBody(w http.ResponseWriter, req *http.Request, create bool) (map[string]any, bool) {
+	var raw map[string]any
+	dec := json.NewDecoder(req.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json")
+		return nil, false
+	}
+
+	out := make(map[string]any, len(raw))
+	for name, v := range raw {
+		f, ok := t.fields[name]
+		if !ok {
+			writeFieldError(w, "unknown_field", name)
+			return nil, false
+		}
+		if name == idField && !create {
+			writeFieldError(w, "readonly_field", name)
+			return nil, false
+		}
+		if v == nil {
+			if !f.Nullable {
+				writeFieldError(w, "field_not_nullable", name)
+				return nil, false
+			}
+			out[name] = nil
+			continue
+		}
+		cv, err := convertValue(f, v)
+		if err != nil {
+			writeFieldError(w, "invalid_field_type", name)
+			return nil, false
+		}
+		out[name] = cv
+	}
+	return out, true
+}
+
+func convertValue(f meta.Field, v any) (any, error) {
+	switch f.Type {
+	case "int":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, errors.New("expected integer")
+		}
+		return n.Int64()
+	case "float":
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, errors.New("expected number")
+		}
+		return n.Float64()
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected string")
+		}
+		return s, nil
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("expected boolean")
+		}
+		return b, nil
+	case "datetime":
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected RFC 3339 string")
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case "json":
+		return json.Marshal(v)
+	default:
+		return v, nil
+	}
+}
+
+func (t table) list(ctx context.Context, st caps.Store) ([]map[string]any, error) {
+	sql := `select ` + t.columns + ` from ` + t.ident
+	if t.hasID {
+		sql += ` order by ` + pgx.Identifier{idField}.Sanitize() + ` desc`
+	}
+	sql += ` limit 100`
+
+	rows, err := st.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToMap)
+}
+
+func (t table) get(ctx context.Context, st caps.Store, id any) (map[string]any, bool, error) {
+	sql := `select ` + t.columns + ` from ` + t.ident + ` where ` + pgx.Identifier{idField}.Sanitize() + ` = $1`
+	return t.one(ctx, st, sql, id)
+}
+
+func (t table) create(ctx context.Context, st caps.Store, values map[string]any) (map[string]any, error) {
+	names, args := t.split(values)
+
+	var sql string
+	if len(names) == 0 {
+		sql = `insert into ` + t.ident + ` default values returning ` + t.columns
+	} else {
+		cols := make([]string, len(names))
+		params := make([]string, len(names))
+		for i, name := range names {
+			cols[i] = pgx.Identifier{name}.Sanitize()
+			params[i] = "$" + strconv.Itoa(i+1)
+		}
+		sql = `insert into ` + t.ident + ` (` + strings.Join(cols, ", ") + `) values (` + strings.Join(params, ", ") + `) returning ` + t.columns
+	}
+
+	item, _, err := t.one(ctx, st, sql, args...)
+	return item, err
+}
+
+func (t table) update(ctx context.Context, st caps.Store, id any, values map[string]any) (map[string]any, bool, error) {
+	names, args := t.split(values)
+
+	sets := make([]string, len(names))
+	for i, name := range names {
+		sets[i] = pgx.Identifier{name}.Sanitize() + " = $" + strconv.Itoa(i+2)
+	}
+	sql := `update ` + t.ident + ` set ` + strings.Join(sets, ", ") +
+		` where ` + pgx.Identifier{idField}.Sanitize() + ` = $1 returning ` + t.columns
+
+	return t.one(ctx, st, sql, append([]any{id}, args...)...)
+}
+
+func (t table) delete(ctx context.Context, st caps.Store, id any) (bool, error) {
+	rows, err := st.ExecResult(ctx, `delete from `+t.ident+` where `+pgx.Identifier{idField}.Sanitize()+` = $1`, id)
+	return rows > 0, err
+}
+
+func (t table) one(ctx context.Context, st caps.Store, sql string, args ...any) (map[string]any, bool, error) {
+	rows, err := st.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	item, err := pgx.CollectOneRow(rows, pgx.RowToMap)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	return item, err == nil, err
+}
+
+// split возвращает имена полей в порядке объявления сущности, чтобы SQL был детерминированным.
+func (t table) split(values map[string]any) ([]string, []any) {
+	names := make([]string, 0, len(values))
+	args := make([]any, 0, len(values))
+	for _, f := range t.entity.Fields {
+		if v, ok := values[f.Name]; ok {
+			names = append(names, f.Name)
+			args = append(args, v)
+		}
+	}
+	return names, args
+}
+
+func writeStoreError(w http.ResponseWriter, err error, code string) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			writeError(w, http.StatusConflict, "conflict")
+			return
+		case "23502", "23503", "23514", "22001", "22P02":
+			writeError(w, http.StatusBadRequest, "constraint_violation")
+			return
+		}
+	}
+	writeError(w, http.StatusInternalServerError, code)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeFieldError(w http.ResponseWriter, code, field string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "field": field})
+}
+
+type errConfig string
+
+func (e errConfig) Error() string { return string(e) }
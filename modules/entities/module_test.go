@@ -0,0 +1,168 @@
+package entities
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+func testTable(t *testing.T) table {
+	t.Helper()
+
+	tb, err := newTable(meta.Entity{
+		Name:  "Note",
+		Table: "notes",
+		Fields: []meta.Field{
+			{Name: "id", Type: "int", Nullable: false},
+			{Name: "title", Type: "string", Nullable: false},
+			{Name: "summary", Type: "string", Nullable: true},
+			{Name: "created_at", Type: "datetime", Nullable: false},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newTable: %v", err)
+	}
+	return tb
+}
+
+func TestNewTable_Invalid(t *testing.T) {
+	cases := map[string]meta.Entity{
+		"no fields":  {Name: "A", Table: "a"},
+		"empty name": {Name: "A", Table: "a", Fields: []meta.Field{{Name: "", Type: "int"}}},
+		"duplicate":  {Name: "A", Table: "a", Fields: []meta.Field{{Name: "x", Type: "int"}, {Name: "x", Type: "int"}}},
+	}
+
+	for name, e := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newTable(e); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestDecodeBody_Valid(t *testing.T) {
+	tb := testTable(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(
+		`{"id": 7, "title": "Hello", "summary": null, "created_at": "2024-01-02T03:04:05Z"}`,
+	))
+	w := httptest.NewRecorder()
+
+	values, ok := tb.decodeBody(w, req, true)
+	if !ok {
+		t.Fatalf("expected ok, got status %d body %s", w.Code, w.Body.String())
+	}
+	if values["id"] != int64(7) {
+		t.Fatalf("id: got %#v", values["id"])
+	}
+	if values["title"] != "Hello" {
+		t.Fatalf("title: got %#v", values["title"])
+	}
+	if v, ok := values["summary"]; !ok || v != nil {
+		t.Fatalf("summary: expected explicit nil, got %#v", v)
+	}
+	if ts, ok := values["created_at"].(time.Time); !ok || ts.Year() != 2024 {
+		t.Fatalf("created_at: got %#v", values["created_at"])
+	}
+
+	names, args := tb.split(values)
+	if strings.Join(names, ",") != "id,title,summary,created_at" || len(args) != 4 {
+		t.Fatalf("split: got %v %v", names, args)
+	}
+}
+
+func TestDecodeBody_Rejected(t *testing.T) {
+	tb := testTable(t)
+
+	cases := []struct {
+		name   string
+		body   string
+		create bool
+		code   string
+		field  string
+	}{
+		{"invalid json", `{"title":`, true, "invalid_json", ""},
+		{"unknown field", `{"nope": 1}`, true, "unknown_field", "nope"},
+		{"not nullable", `{"title": null}`, true, "field_not_nullable", "title"},
+		{"wrong type", `{"title": 5}`, true, "invalid_field_type", "title"},
+		{"fractional int", `{"id": 1.5}`, true, "invalid_field_type", "id"},
+		{"bad datetime", `{"created_at": "yesterday"}`, true, "invalid_field_type", "created_at"},
+		{"id on update", `{"id": 1}`, false, "readonly_field", "id"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			if _, ok := tb.decodeBody(w, req, tc.create); ok {
+				t.Fatalf("expected rejection")
+			}
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status: got %d", w.Code)
+			}
+			var out map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if out["error"] != tc.code || out["field"] != tc.field {
+				t.Fatalf("got %v, want error=%q field=%q", out, tc.code, tc.field)
+			}
+		})
+	}
+}
+
+// nopStore удовлетворяет caps.Store; тест не доходит до БД.
+type nopStore struct{ caps.Store }
+
+// Заметки обслуживает модуль notes (корзина, If-Match, ревизии), поэтому через
+// /entities/notes их нельзя ни изменить, ни удалить.
+func TestRegister_SkipsEntitiesOfModulesWithRoutes(t *testing.T) {
+	r := chi.NewRouter()
+	reg := meta.New()
+
+	caps.NewModuleRoutes(r, "notes", reg).Get("/notes", func(http.ResponseWriter, *http.Request) {})
+	reg.AddEntity(meta.Entity{Name: "Note", Table: "notes", Module: "notes", Fields: []meta.Field{{Name: "id", Type: "int"}}})
+
+	err := New(meta.Entity{Name: "Item", Table: "items", Fields: []meta.Field{{Name: "id", Type: "int"}}}).Register(caps.Setup{
+		Routes: caps.NewModuleRoutes(r, "entities", reg),
+		Meta:   reg,
+		Store:  nopStore{},
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	for _, c := range []struct{ method, path string }{
+		{http.MethodGet, "/entities/notes"},
+		{http.MethodPost, "/entities/notes"},
+		{http.MethodPut, "/entities/notes/1"},
+		{http.MethodDelete, "/entities/notes/1"},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(`{"id": 1}`)))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: got %d, want 404", c.method, c.path, w.Code)
+		}
+	}
+
+	var items bool
+	for _, rt := range reg.Routes() {
+		if strings.HasPrefix(rt.Pattern, "/entities/notes") {
+			t.Errorf("route %s %s is mounted", rt.Method, rt.Pattern)
+		}
+		items = items || rt.Pattern == "/entities/items/{id}"
+	}
+	if !items {
+		t.Fatal("routes of entities without their own module are not mounted")
+	}
+}
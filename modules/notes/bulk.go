@@ -0,0 +1,195 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+const maxBulkOps = 1000
+
+// Режимы POST /notes/bulk: atomic откатывает всё при первой ошибке,
+// partial фиксирует удачные операции. Каждая операция — обычный хелпер заметок
+// со своим RunInTx, который внутри общей транзакции открывает точку сохранения.
+const (
+	bulkAtomic  = "atomic"
+	bulkPartial = "partial"
+)
+
+type bulkOp struct {
+	Op         string   `json:"op"`
+	ID         int64    `json:"id,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Content    string   `json:"content,omitempty"`
+	NotebookID *int64   `json:"notebookId,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	// IfMatch — ETag заметки для update/delete, как заголовок If-Match.
+	IfMatch string `json:"ifMatch,omitempty"`
+}
+
+type bulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status int    `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Note   *Note  `json:"note,omitempty"`
+}
+
+type bulkResponse struct {
+	Mode      string       `json:"mode"`
+	Committed bool         `json:"committed"`
+	Results   []bulkResult `json:"results"`
+}
+
+// run выполняет одну операцию. Ошибки клиента возвращаются в результате,
+// err — только непредвиденные ошибки БД.
+func (op bulkOp) run(ctx context.Context, s caps.Setup, author string) (bulkResult, error) {
+	res := bulkResult{Op: op.Op, ID: op.ID}
+	fail := func(status int, code string) (bulkResult, error) {
+		res.Status, res.Error = status, code
+		return res, nil
+	}
+
+	c := change{ifMatch: op.IfMatch, author: author}
+	var n Note
+	var found bool
+	var err error
+
+	switch op.Op {
+	case "create":
+		if op.Title == "" || op.Content == "" {
+			return fail(http.StatusBadRequest, "title_and_content_required")
+		}
+		if err := checkContent(op.Content); err != nil {
+			return fail(http.StatusBadRequest, err.Error())
+		}
+		tags, terr := normalizeTags(op.Tags)
+		if terr != nil {
+			return fail(http.StatusBadRequest, terr.Error())
+		}
+		n, err = createNote(ctx, s, op.Title, op.Content, op.NotebookID, tags)
+		found = true
+	case "update":
+		if op.ID <= 0 {
+			return fail(http.StatusBadRequest, "invalid_id")
+		}
+		if op.Title == "" || op.Content == "" {
+			return fail(http.StatusBadRequest, "title_and_content_required")
+		}
+		if err := checkContent(op.Content); err != nil {
+			return fail(http.StatusBadRequest, err.Error())
+		}
+		n, found, err = updateNote(ctx, s, op.ID, op.Title, op.Content, c)
+	case "delete":
+		if op.ID <= 0 {
+			return fail(http.StatusBadRequest, "invalid_id")
+		}
+		found, err = deleteNote(ctx, s, op.ID, c)
+	default:
+		return fail(http.StatusBadRequest, "invalid_op")
+	}
+
+	switch {
+	case errors.Is(err, errPreconditionFailed):
+		return fail(http.StatusPreconditionFailed, "precondition_failed")
+	case pgCode(err) == pgForeignKeyViolation:
+		return fail(http.StatusBadRequest, "invalid_notebook")
+	case err != nil:
+		res.Status, res.Error = http.StatusInternalServerError, "internal_error"
+		return res, err
+	case !found:
+		return fail(http.StatusNotFound, "not_found")
+	}
+
+	switch op.Op {
+	case "create":
+		res.Status, res.ID, res.Note = http.StatusCreated, n.ID, &n
+	case "update":
+		res.Status, res.Note = http.StatusOK, &n
+	default:
+		res.Status = http.StatusNoContent
+	}
+	return res, nil
+}
+
+func (r bulkResult) failed() bool { return r.Status >= 400 }
+
+// runBulk выполняет операции в одной транзакции. В режиме atomic выполнение
+// останавливается на первой ошибке, и транзакция откатывается.
+func runBulk(ctx context.Context, s caps.Setup, mode string, ops []bulkOp, author string) (bulkResponse, error) {
+	resp := bulkResponse{Mode: mode}
+	errRollback := errors.New("bulk rollback")
+
+	err := caps.InTx(ctx, s.Store, func(ctx context.Context, _ pgx.Tx) error {
+		resp.Results = make([]bulkResult, 0, len(ops))
+		for i, op := range ops {
+			// неудачная операция уже откатила свою точку сохранения
+			res, err := op.run(ctx, s, author)
+			res.Index = i
+			resp.Results = append(resp.Results, res)
+			switch {
+			case mode == bulkAtomic && err != nil:
+				return err
+			case mode == bulkAtomic && res.failed():
+				return errRollback
+			case err != nil:
+				s.Log.ErrorContext(ctx, "bulk note operation failed", "index", i, "op", op.Op, "error", err)
+			}
+		}
+		return nil
+	})
+
+	if errors.Is(err, errRollback) {
+		return resp, nil
+	}
+	resp.Committed = err == nil
+	return resp, err
+}
+
+func handleBulk(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		mode := req.URL.Query().Get("mode")
+		switch mode {
+		case "":
+			mode = bulkAtomic
+		case bulkAtomic, bulkPartial:
+		default:
+			writeError(w, http.StatusBadRequest, "invalid_mode")
+			return
+		}
+
+		var ops []bulkOp
+		dec := json.NewDecoder(req.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&ops); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json")
+			return
+		}
+		if len(ops) == 0 {
+			writeError(w, http.StatusBadRequest, "operations_required")
+			return
+		}
+		if len(ops) > maxBulkOps {
+			writeError(w, http.StatusBadRequest, "too_many_operations")
+			return
+		}
+
+		resp, err := runBulk(req.Context(), s, mode, ops, changeOf(req).author)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "bulk notes failed", "mode", mode, "error", err)
+			writeError(w, http.StatusInternalServerError, "bulk_failed")
+			return
+		}
+		if !resp.Committed {
+			writeJSON(w, http.StatusUnprocessableEntity, resp)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,53 @@
+package notes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+func TestBulkOpValidation(t *testing.T) {
+	cases := []struct {
+		op   bulkOp
+		code string
+	}{
+		{bulkOp{Op: "create", Title: "t"}, "title_and_content_required"},
+		{bulkOp{Op: "create", Title: "t", Content: "c", Tags: []string{""}}, "invalid_tag"},
+		{bulkOp{Op: "update", ID: 1, Title: "t", Content: strings.Repeat("x", maxContentBytes+1)}, "content_too_long"},
+		{bulkOp{Op: "update", Title: "t", Content: "c"}, "invalid_id"},
+		{bulkOp{Op: "delete"}, "invalid_id"},
+		{bulkOp{Op: "upsert", ID: 1}, "invalid_op"},
+	}
+	for _, c := range cases {
+		// до обращения к БД дело не доходит
+		res, err := c.op.run(context.Background(), caps.Setup{}, "")
+		if err != nil || res.Status != http.StatusBadRequest || res.Error != c.code {
+			t.Fatalf("%+v: expected 400 %s, got %+v, %v", c.op, c.code, res, err)
+		}
+	}
+}
+
+func TestHandleBulkRejectsBadRequests(t *testing.T) {
+	h := handleBulk(caps.Setup{})
+
+	cases := []struct {
+		url, body, code string
+	}{
+		{"/notes/bulk?mode=some", `[]`, "invalid_mode"},
+		{"/notes/bulk", `{}`, "invalid_json"},
+		{"/notes/bulk", `[{"op":"create","extra":1}]`, "invalid_json"},
+		{"/notes/bulk", `[]`, "operations_required"},
+		{"/notes/bulk", "[" + strings.Repeat(`{"op":"delete","id":1},`, maxBulkOps) + `{"op":"delete","id":1}]`, "too_many_operations"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodPost, c.url, strings.NewReader(c.body)))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"`+c.code+`"`) {
+			t.Fatalf("%s %s: expected 400 %s, got %d %s", c.url, c.body[:min(len(c.body), 40)], c.code, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package notes
+
+import (
+	"strings"
+)
+
+// maxDiffCells ограничивает таблицу LCS; для больших текстов diff вырождается
+// в удаление всех старых строк и добавление новых.
+const maxDiffCells = 4_000_000
+
+type diffLine struct {
+	op   byte // ' ', '-' или '+'
+	text string
+}
+
+// lineDiff строит построчный diff по наибольшей общей подпоследовательности.
+func lineDiff(a, b []string) []diffLine {
+	// общие начало и конец не попадают в таблицу
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	out := make([]diffLine, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		out = append(out, diffLine{' ', l})
+	}
+	out = append(out, lcsDiff(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		out = append(out, diffLine{' ', l})
+	}
+	return out
+}
+
+func lcsDiff(a, b []string) []diffLine {
+	out := make([]diffLine, 0, len(a)+len(b))
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			out = append(out, diffLine{'-', l})
+		}
+		for _, l := range b {
+			out = append(out, diffLine{'+', l})
+		}
+		return out
+	}
+
+	// lcs[i][j] — длина LCS для a[i:] и b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// unifiedDiff печатает diff полей заметки в стиле unified diff, по секции на поле.
+// Секция без изменений пропускается.
+func unifiedDiff(fromLabel, toLabel string, a, b noteFields) string {
+	var sb strings.Builder
+	sb.WriteString("--- " + fromLabel + "\n")
+	sb.WriteString("+++ " + toLabel + "\n")
+
+	for _, f := range []struct{ name, a, b string }{
+		{"title", a.title, b.title},
+		{"content", a.content, b.content},
+	} {
+		if f.a == f.b {
+			continue
+		}
+		sb.WriteString("@@ " + f.name + " @@\n")
+		for _, l := range lineDiff(splitLines(f.a), splitLines(f.b)) {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
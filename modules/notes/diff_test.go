@@ -0,0 +1,45 @@
+package notes
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := noteFields{title: "same", content: "one\ntwo\nthree\nfour"}
+	b := noteFields{title: "same", content: "one\n2\nthree\nfour\nfive"}
+
+	got := unifiedDiff("rev 1", "current", a, b)
+	want := "--- rev 1\n+++ current\n@@ content @@\n one\n-two\n+2\n three\n four\n+five\n"
+	if got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := unifiedDiff("rev 1", "rev 2", a, a); strings.Contains(got, "@@") {
+		t.Fatalf("expected no sections for equal notes, got:\n%s", got)
+	}
+}
+
+func TestLineDiffFallback(t *testing.T) {
+	a := make([]string, 3000)
+	b := make([]string, 3000)
+	for i := range a {
+		a[i], b[i] = "a", "b"
+	}
+	d := lineDiff(a, b)
+	if len(d) != 6000 || d[0].op != '-' || d[5999].op != '+' {
+		t.Fatalf("unexpected fallback diff: %d lines", len(d))
+	}
+}
+
+func TestChangeOfTruncatesAuthor(t *testing.T) {
+	req := httptest.NewRequest("PUT", "/notes/1", nil)
+	req.Header.Set(ActorHeader, "  "+strings.Repeat("я", maxAuthorLen)+"  ")
+	req.Header.Set("If-Match", `"x"`)
+
+	c := changeOf(req)
+	if len(c.author) > maxAuthorLen || !strings.HasPrefix(c.author, "я") || c.ifMatch != `"x"` {
+		t.Fatalf("unexpected change: %+v", c)
+	}
+}
@@ -0,0 +1,74 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var errPreconditionFailed = errors.New("precondition failed")
+
+// etagOf строит сильный ETag из id и updated_at (точность PostgreSQL — микросекунды).
+func etagOf(n Note) string {
+	return etagFor(n.ID, n.UpdatedAt)
+}
+
+func etagFor(id int64, updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(id, 36) + "-" + strconv.FormatInt(updatedAt.UnixMicro(), 36) + `"`
+}
+
+// pageETag — ETag HTML-страницы заметки. Страница и JSON отдаются по одному URL
+// (Vary: Accept), поэтому у страницы свой сильный тег, иначе If-None-Match с тегом
+// JSON получил бы 304 для HTML.
+func pageETag(n Note) string {
+	etag := etagOf(n)
+	return etag[:len(etag)-1] + `-html"`
+}
+
+// etagMatches проверяет заголовок If-Match (weak == false, сильное сравнение)
+// или If-None-Match (weak == true, слабое сравнение, RFC 9110 §8.8.3.2).
+func etagMatches(header, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// lockNote блокирует строку до конца транзакции, возвращает её текущее состояние
+// и сверяет ETag с If-Match. Пустой заголовок означает безусловную операцию.
+func lockNote(ctx context.Context, tx pgx.Tx, id int64, ifMatch string) (Note, bool, error) {
+	var n Note
+	err := tx.QueryRow(ctx, `
+		select id, title, content, created_at, updated_at
+		from notes
+		where id = $1 and deleted_at is null
+		for update
+	`, id).Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Note{}, false, nil
+		}
+		return Note{}, false, err
+	}
+	if ifMatch != "" && !etagMatches(ifMatch, etagOf(n), false) {
+		return Note{}, true, errPreconditionFailed
+	}
+	return n, true, nil
+}
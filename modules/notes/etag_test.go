@@ -0,0 +1,41 @@
+package notes
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := etagFor(42, time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC))
+
+	cases := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{etag, false, true},
+		{"*", false, true},
+		{`"other", ` + etag, false, true},
+		{`"other"`, false, false},
+		{"W/" + etag, false, false},
+		{"W/" + etag, true, true},
+		{etagFor(42, time.Date(2024, 1, 2, 3, 4, 5, 123457000, time.UTC)), false, false},
+	}
+	for _, c := range cases {
+		if got := etagMatches(c.header, etag, c.weak); got != c.want {
+			t.Errorf("etagMatches(%q, weak=%v) = %v, want %v", c.header, c.weak, got, c.want)
+		}
+	}
+}
+
+func TestPageETag(t *testing.T) {
+	n := Note{ID: 42, UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)}
+	page := pageETag(n)
+	if page == etagOf(n) || etagMatches(etagOf(n), page, true) {
+		t.Fatalf("page ETag %s must differ from JSON ETag %s", page, etagOf(n))
+	}
+	if !strings.HasPrefix(page, `"`) || !strings.HasSuffix(page, `-html"`) {
+		t.Fatalf("page ETag = %s", page)
+	}
+}
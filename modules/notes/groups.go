@@ -0,0 +1,373 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/meta"
+)
+
+const maxNameLen = 100
+
+// named — тег или блокнот: у обоих есть только имя, а noteCount считает заметки вне корзины.
+type named struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	NoteCount int64     `json:"noteCount" db:"note_count"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type namedReq struct {
+	Name string `json:"name"`
+}
+
+// namedKind описывает таблицу тегов или блокнотов. count — подзапрос числа заметок
+// для строки с алиасом x, touch — обновление updated_at заметок строки с id = $1.
+type namedKind struct {
+	path     string
+	table    string
+	entity   string
+	relation meta.Relation
+	count    string
+	touch    string
+}
+
+var (
+	tagKind = namedKind{
+		path:     "/tags",
+		table:    "tags",
+		entity:   "Tag",
+		relation: meta.Relation{Name: "notes", Kind: meta.ManyToMany, Entity: "Note", Through: "note_tags"},
+		count: `(select count(*) from note_tags nt join notes n on n.id = nt.note_id
+			where nt.tag_id = x.id and n.deleted_at is null)`,
+		touch: `update notes set updated_at = now() where id in (select note_id from note_tags where tag_id = $1)`,
+	}
+	notebookKind = namedKind{
+		path:     "/notebooks",
+		table:    "notebooks",
+		entity:   "Notebook",
+		relation: meta.Relation{Name: "notes", Kind: meta.HasMany, Entity: "Note", Field: "notebook_id"},
+		count:    `(select count(*) from notes n where n.notebook_id = x.id and n.deleted_at is null)`,
+		touch:    `update notes set updated_at = now() where notebook_id = $1`,
+	}
+)
+
+func normalizeName(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", queryError("name_required")
+	}
+	if utf8.RuneCountInString(s) > maxNameLen {
+		return "", queryError("name_too_long")
+	}
+	return s, nil
+}
+
+func (k namedKind) columns() string {
+	return `x.id, x.name, ` + k.count + ` as note_count, x.created_at`
+}
+
+func (k namedKind) list(ctx context.Context, s caps.Setup) ([]named, error) {
+	return caps.Select[named](ctx, s.Store, `select `+k.columns()+` from `+k.table+` x order by x.name`)
+}
+
+func (k namedKind) get(ctx context.Context, s caps.Setup, id int64) (named, bool, error) {
+	return caps.Get[named](ctx, s.Store, `select `+k.columns()+` from `+k.table+` x where x.id = $1`, id)
+}
+
+func (k namedKind) create(ctx context.Context, s caps.Setup, name string) (named, error) {
+	v, _, err := caps.Get[named](ctx, s.Store, `
+		insert into `+k.table+` as x (name) values ($1)
+		returning `+k.columns(), name)
+	return v, err
+}
+
+// rename и delete меняют tags или notebookId в заметках, поэтому, как organizeNote,
+// обновляют их updated_at: иначе If-None-Match со старым ETag получил бы 304.
+func (k namedKind) rename(ctx context.Context, s caps.Setup, id int64, name string) (v named, found bool, err error) {
+	err = s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, k.touch, id); err != nil {
+			return err
+		}
+		v, found, err = caps.Get[named](ctx, tx, `
+			update `+k.table+` as x set name = $2 where x.id = $1
+			returning `+k.columns(), id, name)
+		return err
+	})
+	return v, found, err
+}
+
+func (k namedKind) delete(ctx context.Context, s caps.Setup, id int64) (found bool, err error) {
+	err = s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, k.touch, id); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `delete from `+k.table+` where id = $1`, id)
+		found = tag.RowsAffected() > 0
+		return err
+	})
+	return found, err
+}
+
+// register публикует сущность и CRUD-маршруты тегов или блокнотов.
+func (k namedKind) register(s caps.Setup, module string) {
+	s.Meta.AddEntity(meta.Entity{
+		Name:   k.entity,
+		Table:  k.table,
+		Module: module,
+		Fields: []meta.Field{
+			{Name: "id", Type: "int", Nullable: false},
+			{Name: "name", Type: "string", Nullable: false},
+			{Name: "created_at", Type: "datetime", Nullable: false},
+		},
+		Relations: []meta.Relation{k.relation},
+	})
+
+	noun := strings.ToLower(k.entity)
+
+	s.Routes.Route(k.path, func(r caps.Routes) {
+		r.Get("/", func(w http.ResponseWriter, req *http.Request) {
+			items, err := k.list(req.Context(), s)
+			if err != nil {
+				s.Log.ErrorContext(req.Context(), "list "+k.table+" failed", "error", err)
+				writeError(w, http.StatusInternalServerError, "list_failed")
+				return
+			}
+			writeJSON(w, http.StatusOK, items)
+		}, caps.Summary("List "+k.table+" with note counts, ordered by name"),
+			caps.Returns(http.StatusOK, []named{}), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Post("/", func(w http.ResponseWriter, req *http.Request) {
+			name, ok := decodeName(w, req)
+			if !ok {
+				return
+			}
+			v, err := k.create(req.Context(), s, name)
+			if err != nil {
+				writeNamedError(w, req, s, err, "create "+noun, "create_failed")
+				return
+			}
+			writeJSON(w, http.StatusCreated, v)
+		}, caps.Summary("Create "+noun), caps.Accepts(namedReq{}), caps.Returns(http.StatusCreated, named{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusConflict, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/{id}", func(w http.ResponseWriter, req *http.Request) {
+			id, ok := parseID(w, req)
+			if !ok {
+				return
+			}
+			v, found, err := k.get(req.Context(), s, id)
+			if err != nil {
+				s.Log.ErrorContext(req.Context(), "get "+noun+" failed", "error", err)
+				writeError(w, http.StatusInternalServerError, "get_failed")
+				return
+			}
+			if !found {
+				writeError(w, http.StatusNotFound, "not_found")
+				return
+			}
+			writeJSON(w, http.StatusOK, v)
+		}, caps.Summary("Get "+noun), caps.Returns(http.StatusOK, named{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Put("/{id}", func(w http.ResponseWriter, req *http.Request) {
+			id, ok := parseID(w, req)
+			if !ok {
+				return
+			}
+			name, ok := decodeName(w, req)
+			if !ok {
+				return
+			}
+			v, found, err := k.rename(req.Context(), s, id, name)
+			if err != nil {
+				writeNamedError(w, req, s, err, "rename "+noun, "update_failed")
+				return
+			}
+			if !found {
+				writeError(w, http.StatusNotFound, "not_found")
+				return
+			}
+			writeJSON(w, http.StatusOK, v)
+		}, caps.Summary("Rename "+noun), caps.Accepts(namedReq{}), caps.Returns(http.StatusOK, named{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusConflict, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Delete("/{id}", func(w http.ResponseWriter, req *http.Request) {
+			id, ok := parseID(w, req)
+			if !ok {
+				return
+			}
+			found, err := k.delete(req.Context(), s, id)
+			if err != nil {
+				s.Log.ErrorContext(req.Context(), "delete "+noun+" failed", "error", err)
+				writeError(w, http.StatusInternalServerError, "delete_failed")
+				return
+			}
+			if !found {
+				writeError(w, http.StatusNotFound, "not_found")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}, caps.Summary("Delete "+noun+"; its notes are kept"), caps.Returns(http.StatusNoContent, nil),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
+	})
+}
+
+func decodeName(w http.ResponseWriter, req *http.Request) (string, bool) {
+	var in namedReq
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json")
+		return "", false
+	}
+	name, err := normalizeName(in.Name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return name, true
+}
+
+func writeNamedError(w http.ResponseWriter, req *http.Request, s caps.Setup, err error, op, code string) {
+	if pgCode(err) == pgUniqueViolation {
+		writeError(w, http.StatusConflict, "name_taken")
+		return
+	}
+	s.Log.ErrorContext(req.Context(), op+" failed", "error", err)
+	writeError(w, http.StatusInternalServerError, code)
+}
+
+// normalizeTags обрезает пробелы, проверяет имена и убирает повторы, сохраняя порядок.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		name, err := normalizeName(t)
+		if err != nil {
+			return nil, queryError("invalid_tag")
+		}
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+// setNoteTags заменяет набор тегов заметки, создавая недостающие теги.
+func setNoteTags(ctx context.Context, tx pgx.Tx, id int64, tags []string) error {
+	if _, err := tx.Exec(ctx, `
+		insert into tags (name)
+		select unnest($1::text[])
+		on conflict (name) do nothing
+	`, tags); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `delete from note_tags where note_id = $1`, id); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		insert into note_tags (note_id, tag_id)
+		select $1, id from tags where name = any($2::text[])
+	`, id, tags)
+	return err
+}
+
+type noteTagsReq struct {
+	Tags []string `json:"tags"`
+}
+
+type noteNotebookReq struct {
+	NotebookID *int64 `json:"notebookId"`
+}
+
+// organizeNote меняет теги или блокнот заметки и обновляет updated_at, чтобы сменился ETag.
+func organizeNote(ctx context.Context, s caps.Setup, id int64, ifMatch string, apply func(tx pgx.Tx) error) (Note, bool, error) {
+	var n Note
+	var found bool
+
+	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		_, exists, err := lockNote(ctx, tx, id, ifMatch)
+		if err != nil || !exists {
+			return err
+		}
+		if err := apply(tx); err != nil {
+			return err
+		}
+		found = true
+		return tx.QueryRow(ctx, `
+			update notes set updated_at = now() where id = $1
+			returning `+noteColumns("notes"), id).Scan(noteDest(&n)...)
+	})
+
+	return n, found, err
+}
+
+func writeOrganized(w http.ResponseWriter, req *http.Request, s caps.Setup, n Note, found bool, err error) {
+	switch {
+	case errors.Is(err, errPreconditionFailed):
+		writeError(w, http.StatusPreconditionFailed, "precondition_failed")
+	case pgCode(err) == pgForeignKeyViolation:
+		writeError(w, http.StatusBadRequest, "invalid_notebook")
+	case err != nil:
+		s.Log.ErrorContext(req.Context(), "organize note failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "update_failed")
+	case !found:
+		writeError(w, http.StatusNotFound, "not_found")
+	default:
+		w.Header().Set("ETag", etagOf(n))
+		writeJSON(w, http.StatusOK, n)
+	}
+}
+
+func handleSetTags(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		var in noteTagsReq
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json")
+			return
+		}
+		tags, err := normalizeTags(in.Tags)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		n, found, err := organizeNote(req.Context(), s, id, req.Header.Get("If-Match"), func(tx pgx.Tx) error {
+			return setNoteTags(req.Context(), tx, id, tags)
+		})
+		writeOrganized(w, req, s, n, found, err)
+	}
+}
+
+func handleSetNotebook(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		var in noteNotebookReq
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_json")
+			return
+		}
+
+		n, found, err := organizeNote(req.Context(), s, id, req.Header.Get("If-Match"), func(tx pgx.Tx) error {
+			_, err := tx.Exec(req.Context(), `update notes set notebook_id = $2 where id = $1`, id, in.NotebookID)
+			return err
+		})
+		writeOrganized(w, req, s, n, found, err)
+	}
+}
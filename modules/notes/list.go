@@ -0,0 +1,343 @@
+package notes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	legacyLimit  = 100
+)
+
+// sortColumns — допустимые значения ?sort= (имя поля в JSON -> колонка).
+var sortColumns = map[string]string{
+	"id":        "id",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+	"title":     "title",
+}
+
+type listQuery struct {
+	limit  int
+	offset int
+	// offsetMode — пагинация limit/offset вместо keyset.
+	offsetMode bool
+	cursor     *cursor
+
+	sortField string
+	desc      bool
+
+	title       string
+	createdFrom *time.Time
+	createdTo   *time.Time
+	// tags — заметка должна иметь все перечисленные теги.
+	tags     []string
+	notebook int64
+
+	// envelope == false — старый формат ответа (массив), используется без listParams.
+	envelope bool
+}
+
+// listParams — параметры, с которыми список отвечает конвертом. Прочие (?render=,
+// ?_= от сброса кэша) формат ответа не меняют.
+var listParams = []string{"limit", "offset", "cursor", "sort", "title", "createdFrom", "createdTo", "tag", "notebook"}
+
+func hasListParams(v url.Values) bool {
+	for _, p := range listParams {
+		if _, ok := v[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+type listPage struct {
+	Items []Note   `json:"items"`
+	Page  pageInfo `json:"page"`
+}
+
+type pageInfo struct {
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Sort       string `json:"sort,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+type queryError string
+
+func (e queryError) Error() string { return string(e) }
+
+func parseListQuery(v url.Values) (listQuery, error) {
+	q := listQuery{
+		limit:     legacyLimit,
+		sortField: "id",
+		desc:      true,
+		envelope:  hasListParams(v),
+	}
+	if !q.envelope {
+		return q, nil
+	}
+
+	limit, offset, offsetMode, err := parsePaging(v)
+	if err != nil {
+		return listQuery{}, err
+	}
+	q.limit, q.offset, q.offsetMode = limit, offset, offsetMode
+
+	if s := v.Get("sort"); s != "" {
+		field := strings.TrimPrefix(s, "-")
+		if _, ok := sortColumns[field]; !ok {
+			return listQuery{}, queryError("invalid_sort")
+		}
+		q.sortField = field
+		q.desc = strings.HasPrefix(s, "-")
+	}
+
+	if c := v.Get("cursor"); c != "" {
+		if q.offsetMode {
+			return listQuery{}, queryError("cursor_and_offset")
+		}
+		cur, err := decodeCursor(c)
+		if err != nil || cur.Sort != q.sortParam() {
+			return listQuery{}, queryError("invalid_cursor")
+		}
+		q.cursor = &cur
+		if _, err := q.cursorValue(); err != nil {
+			return listQuery{}, err
+		}
+	}
+
+	q.title = strings.TrimSpace(v.Get("title"))
+	if q.createdFrom, err = parseTimeParam(v.Get("createdFrom")); err != nil {
+		return listQuery{}, queryError("invalid_created_from")
+	}
+	if q.createdTo, err = parseTimeParam(v.Get("createdTo")); err != nil {
+		return listQuery{}, queryError("invalid_created_to")
+	}
+	if q.tags, err = normalizeTags(v["tag"]); err != nil {
+		return listQuery{}, err
+	}
+	if s := v.Get("notebook"); s != "" {
+		q.notebook, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || q.notebook <= 0 {
+			return listQuery{}, queryError("invalid_notebook")
+		}
+	}
+
+	return q, nil
+}
+
+// parsePaging разбирает общие для списков параметры limit/offset.
+func parsePaging(v url.Values) (limit, offset int, offsetMode bool, err error) {
+	limit = defaultLimit
+	if s := v.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			return 0, 0, false, queryError("invalid_limit")
+		}
+	}
+	if s := v.Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, false, queryError("invalid_offset")
+		}
+		offsetMode = true
+	}
+	return limit, offset, offsetMode, nil
+}
+
+func parseTimeParam(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (q listQuery) sortParam() string {
+	if q.desc {
+		return "-" + q.sortField
+	}
+	return q.sortField
+}
+
+// build возвращает SQL со строкой limit+1: лишняя строка сигнализирует о следующей странице.
+func (q listQuery) build() (string, []any, error) {
+	col := sortColumns[q.sortField]
+	dir, cmp := "asc", ">"
+	if q.desc {
+		dir, cmp = "desc", "<"
+	}
+
+	where := []string{`deleted_at is null`}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.title != "" {
+		where = append(where, `title ilike `+arg("%"+escapeLike(q.title)+"%"))
+	}
+	if q.createdFrom != nil {
+		where = append(where, `created_at >= `+arg(*q.createdFrom))
+	}
+	if q.createdTo != nil {
+		where = append(where, `created_at < `+arg(*q.createdTo))
+	}
+	for _, tag := range q.tags {
+		where = append(where, `exists (
+			select 1 from note_tags nt join tags t on t.id = nt.tag_id
+			where nt.note_id = notes.id and t.name = `+arg(tag)+`)`)
+	}
+	if q.notebook > 0 {
+		where = append(where, `notebook_id = `+arg(q.notebook))
+	}
+	if q.cursor != nil {
+		if col == "id" {
+			where = append(where, `id `+cmp+` `+arg(q.cursor.ID))
+		} else {
+			v, err := q.cursorValue()
+			if err != nil {
+				return "", nil, err
+			}
+			where = append(where, `(`+col+`, id) `+cmp+` (`+arg(v)+`, `+arg(q.cursor.ID)+`)`)
+		}
+	}
+
+	sql := `
+		select ` + noteColumns("notes") + `
+		from notes
+		where ` + strings.Join(where, " and ") + `
+		order by ` + col + ` ` + dir
+	if col != "id" {
+		sql += `, id ` + dir
+	}
+	sql += `
+		limit ` + arg(q.limit+1)
+	if q.offsetMode {
+		sql += ` offset ` + arg(q.offset)
+	}
+
+	return sql, args, nil
+}
+
+func (q listQuery) cursorValue() (any, error) {
+	switch sortColumns[q.sortField] {
+	case "created_at", "updated_at":
+		t, err := time.Parse(time.RFC3339Nano, q.cursor.Value)
+		if err != nil {
+			return nil, queryError("invalid_cursor")
+		}
+		return t, nil
+	default:
+		return q.cursor.Value, nil
+	}
+}
+
+func (q listQuery) cursorFor(n Note) string {
+	c := cursor{Sort: q.sortParam(), ID: n.ID}
+	switch q.sortField {
+	case "createdAt":
+		c.Value = n.CreatedAt.Format(time.RFC3339Nano)
+	case "updatedAt":
+		c.Value = n.UpdatedAt.Format(time.RFC3339Nano)
+	case "title":
+		c.Value = n.Title
+	}
+	return encodeCursor(c)
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, err
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return cursor{}, err
+	}
+	if c.ID <= 0 {
+		return cursor{}, errors.New("cursor without id")
+	}
+	return c, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func listNotes(ctx context.Context, s caps.Setup, q listQuery) ([]Note, error) {
+	sql, args, err := q.build()
+	if err != nil {
+		return nil, err
+	}
+
+	return collectNotes(s.Store.Query(ctx, sql, args...))
+}
+
+// pageOf обрезает лишнюю строку и собирает метаданные страницы со ссылкой на следующую.
+func (q listQuery) pageOf(req *http.Request, items []Note) listPage {
+	p := listPage{Items: items, Page: pageInfo{Limit: q.limit, Sort: q.sortParam()}}
+	if q.offsetMode {
+		offset := q.offset
+		p.Page.Offset = &offset
+	}
+
+	if len(items) <= q.limit {
+		return p
+	}
+	p.Items = items[:q.limit]
+
+	next := req.URL.Query()
+	if q.offsetMode {
+		next.Set("offset", strconv.Itoa(q.offset+q.limit))
+	} else {
+		p.Page.NextCursor = q.cursorFor(p.Items[len(p.Items)-1])
+		next.Set("cursor", p.Page.NextCursor)
+	}
+	// у старого клиента без параметров следующая страница уже придёт в новом формате
+	next.Set("limit", strconv.Itoa(q.limit))
+	next.Set("sort", q.sortParam())
+	p.Page.Next = req.URL.Path + "?" + next.Encode()
+
+	return p
+}
+
+func writeList(w http.ResponseWriter, req *http.Request, q listQuery, items []Note) {
+	p := q.pageOf(req, items)
+	if p.Page.Next != "" {
+		w.Header().Set("Link", "<"+p.Page.Next+`>; rel="next"`)
+	}
+	if !q.envelope {
+		writeJSON(w, http.StatusOK, p.Items)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
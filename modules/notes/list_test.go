@@ -0,0 +1,158 @@
+package notes
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseListQuery_Legacy(t *testing.T) {
+	q, err := parseListQuery(url.Values{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.envelope || q.limit != legacyLimit || q.sortParam() != "-id" {
+		t.Fatalf("unexpected legacy query: %+v", q)
+	}
+}
+
+func TestParseListQuery_UnrelatedParams(t *testing.T) {
+	for _, raw := range []string{"render=html", "_=123", "foo=bar&_=1"} {
+		v, _ := url.ParseQuery(raw)
+		q, err := parseListQuery(v)
+		if err != nil || q.envelope {
+			t.Errorf("%s: envelope=%v err=%v, want legacy array", raw, q.envelope, err)
+		}
+	}
+	v, _ := url.ParseQuery("_=123&tag=go")
+	if q, err := parseListQuery(v); err != nil || !q.envelope {
+		t.Errorf("list param must switch to envelope: %+v %v", q, err)
+	}
+}
+
+func TestParseListQuery_Rejected(t *testing.T) {
+	cases := map[string]string{
+		"limit=0":               "invalid_limit",
+		"limit=101":             "invalid_limit",
+		"offset=-1":             "invalid_offset",
+		"sort=content":          "invalid_sort",
+		"cursor=abc!":           "invalid_cursor",
+		"createdFrom=yesterday": "invalid_created_from",
+		"createdTo=2024-01-01":  "invalid_created_to",
+		"offset=10&cursor=" + encodeCursor(cursor{Sort: "-id", ID: 5}):                        "cursor_and_offset",
+		"sort=title&cursor=" + encodeCursor(cursor{Sort: "-id", ID: 5}):                       "invalid_cursor",
+		"sort=createdAt&cursor=" + encodeCursor(cursor{Sort: "createdAt", Value: "x", ID: 5}): "invalid_cursor",
+	}
+
+	for raw, want := range cases {
+		t.Run(raw, func(t *testing.T) {
+			v, err := url.ParseQuery(raw)
+			if err != nil {
+				t.Fatalf("parse query: %v", err)
+			}
+			_, err = parseListQuery(v)
+			if err == nil || err.Error() != want {
+				t.Fatalf("got %v, want %s", err, want)
+			}
+		})
+	}
+}
+
+func TestListQuery_BuildKeyset(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	v := url.Values{
+		"sort":        {"-createdAt"},
+		"limit":       {"10"},
+		"title":       {"50%_off"},
+		"createdFrom": {"2024-01-01T00:00:00Z"},
+		"cursor":      {encodeCursor(cursor{Sort: "-createdAt", Value: ts.Format(time.RFC3339Nano), ID: 42})},
+	}
+	q, err := parseListQuery(v)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	sql, args, err := q.build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	for _, frag := range []string{
+		"title ilike $1",
+		"created_at >= $2",
+		"(created_at, id) < ($3, $4)",
+		"order by created_at desc, id desc",
+		"limit $5",
+	} {
+		if !strings.Contains(sql, frag) {
+			t.Fatalf("sql %q does not contain %q", sql, frag)
+		}
+	}
+	if strings.Contains(sql, "offset") {
+		t.Fatalf("keyset query must not use offset: %s", sql)
+	}
+	if args[0] != `%50\%\_off%` || !args[2].(time.Time).Equal(ts) || args[3] != int64(42) || args[4] != 11 {
+		t.Fatalf("unexpected args: %#v", args)
+	}
+}
+
+func TestListQuery_PageOf(t *testing.T) {
+	q, err := parseListQuery(url.Values{"sort": {"id"}, "limit": {"2"}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/notes?sort=id&limit=2", nil)
+	p := q.pageOf(req, []Note{{ID: 1}, {ID: 2}, {ID: 3}})
+
+	if len(p.Items) != 2 {
+		t.Fatalf("expected extra row to be trimmed, got %d items", len(p.Items))
+	}
+	c, err := decodeCursor(p.Page.NextCursor)
+	if err != nil || c.ID != 2 || c.Sort != "id" {
+		t.Fatalf("unexpected next cursor %+v (%v)", c, err)
+	}
+	next, err := url.Parse(p.Page.Next)
+	if err != nil || next.Path != "/notes" || next.Query().Get("cursor") != p.Page.NextCursor {
+		t.Fatalf("unexpected next link %q", p.Page.Next)
+	}
+
+	last := q.pageOf(req, []Note{{ID: 1}})
+	if last.Page.Next != "" || last.Page.NextCursor != "" {
+		t.Fatalf("expected no next page, got %+v", last.Page)
+	}
+
+	oq, _ := parseListQuery(url.Values{"offset": {"4"}, "limit": {"2"}})
+	op := oq.pageOf(httptest.NewRequest("GET", "/notes?offset=4&limit=2", nil), []Note{{ID: 1}, {ID: 2}, {ID: 3}})
+	if op.Page.Offset == nil || *op.Page.Offset != 4 || !strings.Contains(op.Page.Next, "offset=6") {
+		t.Fatalf("unexpected offset page: %+v", op.Page)
+	}
+}
+
+func TestParseListQueryTagsAndNotebook(t *testing.T) {
+	q, err := parseListQuery(url.Values{"tag": {" work ", "urgent", "work"}, "notebook": {"7"}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(q.tags) != 2 || q.tags[0] != "work" || q.notebook != 7 {
+		t.Fatalf("unexpected query: %+v", q)
+	}
+
+	sql, args, err := q.build()
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if strings.Count(sql, "t.name = $") != 2 || !strings.Contains(sql, "notebook_id = $3") || len(args) != 4 {
+		t.Fatalf("unexpected sql %q args %v", sql, args)
+	}
+
+	for code, v := range map[string]url.Values{
+		"invalid_tag":      {"tag": {" "}},
+		"invalid_notebook": {"notebook": {"x"}},
+	} {
+		if _, err := parseListQuery(v); err == nil || err.Error() != code {
+			t.Fatalf("expected %s, got %v", code, err)
+		}
+	}
+}
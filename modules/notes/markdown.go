@@ -0,0 +1,872 @@
+package notes
+
+import (
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Рендерер поддерживает подмножество Markdown: заголовки (# и setext), абзацы,
+// цитаты, списки (в том числе вложенные), блоки кода, горизонтальные линии,
+// а внутри строк — `код`, **жирный**, *курсив*, ~~зачёркнутый~~, ссылки, картинки
+// и <автоссылки>. Сырой HTML не пропускается: весь текст экранируется, а ссылки
+// и картинки с небезопасной схемой превращаются в обычный текст.
+
+const excerptLen = 200
+
+// summaryScanBytes — сколько начала заметки разбирает список: выдержке хватает
+// первых абзацев, а полный разбор до 100 заметок по 256 КБ на каждый запрос дорог.
+const summaryScanBytes = 16 << 10
+
+// maxNesting ограничивает вложенность цитат, списков, ссылок и выделений, чтобы
+// разбор патологического ввода оставался линейным: глубже разметка остаётся текстом.
+const maxNesting = 32
+
+// Heading — заголовок из оглавления заметки; ID совпадает с атрибутом id в HTML.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockCode
+	blockQuote
+	blockList
+	blockRule
+)
+
+type mdBlock struct {
+	kind  blockKind
+	level int      // уровень заголовка
+	lines []string // строки абзаца, заголовка или кода
+	lang  string   // язык блока кода
+
+	children []mdBlock   // содержимое цитаты
+	items    [][]mdBlock // пункты списка
+	ordered  bool
+	start    int
+	id       string // id заголовка
+}
+
+type markdownDoc struct {
+	blocks []mdBlock
+	ids    map[string]int
+	// depth — текущая вложенность цитат и списков
+	depth int
+}
+
+func parseMarkdown(src string) *markdownDoc {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	lines := strings.Split(src, "\n")
+	for i, l := range lines {
+		lines[i] = expandTabs(l)
+	}
+
+	d := &markdownDoc{ids: map[string]int{}}
+	d.blocks = d.parseBlocks(lines)
+	return d
+}
+
+func expandTabs(l string) string {
+	if !strings.Contains(l, "\t") {
+		return l
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range l {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+func (d *markdownDoc) parseBlocks(lines []string) []mdBlock {
+	var out []mdBlock
+	nest := d.depth < maxNesting
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case fenceOf(line) != "":
+			fence := fenceOf(line)
+			b := mdBlock{kind: blockCode}
+			if info := strings.Fields(trimmed[len(fence):]); len(info) > 0 {
+				b.lang = info[0]
+			}
+			i++
+			for ; i < len(lines); i++ {
+				if t := strings.TrimSpace(lines[i]); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+					i++
+					break
+				}
+				b.lines = append(b.lines, lines[i])
+			}
+			out = append(out, b)
+
+		case headingLevel(line) > 0:
+			level := headingLevel(line)
+			out = append(out, d.heading(level, headingText(trimmed, level)))
+			i++
+
+		case isRule(line):
+			out = append(out, mdBlock{kind: blockRule})
+			i++
+
+		case nest && quoteText(line) != nil:
+			var inner []string
+			for ; i < len(lines); i++ {
+				q := quoteText(lines[i])
+				if q == nil {
+					break
+				}
+				inner = append(inner, *q)
+			}
+			out = append(out, mdBlock{kind: blockQuote, children: d.nested(inner)})
+
+		case nest && listMarker(line) != nil:
+			var b mdBlock
+			b, i = d.parseList(lines, i)
+			out = append(out, b)
+
+		default:
+			var para []string
+			for ; i < len(lines); i++ {
+				l := lines[i]
+				t := strings.TrimSpace(l)
+				if len(para) > 0 && setextLevel(t) > 0 {
+					out = append(out, d.heading(setextLevel(t), strings.TrimSpace(strings.Join(para, " "))))
+					para = nil
+					i++
+					break
+				}
+				// на предельной глубине маркеры списков и цитат — обычный текст
+				if t == "" || (len(para) > 0 && startsBlock(l) && (nest || !nestsBlock(l))) {
+					break
+				}
+				para = append(para, strings.TrimLeft(l, " "))
+			}
+			if len(para) > 0 {
+				out = append(out, mdBlock{kind: blockParagraph, lines: para})
+			}
+		}
+	}
+	return out
+}
+
+func (d *markdownDoc) heading(level int, text string) mdBlock {
+	slug := slugify(renderInline(text, true))
+	if n := d.ids[slug]; n > 0 {
+		d.ids[slug] = n + 1
+		slug += "-" + strconv.Itoa(n)
+	} else {
+		d.ids[slug] = 1
+	}
+	return mdBlock{kind: blockHeading, level: level, lines: []string{text}, id: slug}
+}
+
+// parseList собирает пункты списка одного типа, начиная со строки i.
+func (d *markdownDoc) parseList(lines []string, i int) (mdBlock, int) {
+	first := listMarker(lines[i])
+	b := mdBlock{kind: blockList, ordered: first.ordered, start: first.start}
+
+	for i < len(lines) {
+		m := listMarker(lines[i])
+		if m == nil || m.ordered != first.ordered || m.indent > first.indent+1 {
+			break
+		}
+		item := []string{lines[i][m.width:]}
+		i++
+		for i < len(lines) {
+			l := lines[i]
+			if strings.TrimSpace(l) == "" {
+				// пустая строка продолжает пункт, только если дальше идёт вложенный текст
+				j := i
+				for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+					j++
+				}
+				if j < len(lines) && indentOf(lines[j]) >= m.width {
+					item = append(item, lines[i:j]...)
+					i = j
+					continue
+				}
+				break
+			}
+			if indentOf(l) >= m.width {
+				item = append(item, l[m.width:])
+				i++
+				continue
+			}
+			if startsBlock(l) {
+				break
+			}
+			// ленивое продолжение абзаца
+			item = append(item, strings.TrimLeft(l, " "))
+			i++
+		}
+		b.items = append(b.items, d.nested(item))
+
+		// пустые строки между пунктами одного списка
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j < len(lines) && j > i {
+			if next := listMarker(lines[j]); next != nil && next.ordered == first.ordered && next.indent <= first.indent+1 {
+				i = j
+			}
+		}
+	}
+	return b, i
+}
+
+// nested разбирает содержимое цитаты или пункта списка уровнем глубже.
+func (d *markdownDoc) nested(lines []string) []mdBlock {
+	d.depth++
+	defer func() { d.depth-- }()
+	return d.parseBlocks(lines)
+}
+
+func nestsBlock(l string) bool {
+	return quoteText(l) != nil || listMarker(l) != nil
+}
+
+func startsBlock(l string) bool {
+	return fenceOf(l) != "" || headingLevel(l) > 0 || isRule(l) || quoteText(l) != nil || listMarker(l) != nil
+}
+
+func indentOf(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+func fenceOf(l string) string {
+	if indentOf(l) > 3 {
+		return ""
+	}
+	t := strings.TrimLeft(l, " ")
+	for _, f := range []string{"```", "~~~"} {
+		if strings.HasPrefix(t, f) {
+			n := len(t) - len(strings.TrimLeft(t, f[:1]))
+			if f[0] == '`' && strings.Contains(t[n:], "`") {
+				return ""
+			}
+			return t[:n]
+		}
+	}
+	return ""
+}
+
+func headingLevel(l string) int {
+	if indentOf(l) > 3 {
+		return 0
+	}
+	t := strings.TrimLeft(l, " ")
+	n := len(t) - len(strings.TrimLeft(t, "#"))
+	if n < 1 || n > 6 || (len(t) > n && t[n] != ' ') {
+		return 0
+	}
+	return n
+}
+
+func headingText(t string, level int) string {
+	t = strings.TrimSpace(t[level:])
+	// закрывающие # отбрасываются, если отделены пробелом
+	if s := strings.TrimRight(t, "#"); s != t && (s == "" || strings.HasSuffix(s, " ")) {
+		t = strings.TrimSpace(s)
+	}
+	return t
+}
+
+func setextLevel(t string) int {
+	switch {
+	case t != "" && strings.Trim(t, "=") == "":
+		return 1
+	case len(t) >= 2 && strings.Trim(t, "-") == "":
+		return 2
+	}
+	return 0
+}
+
+func isRule(l string) bool {
+	if indentOf(l) > 3 {
+		return false
+	}
+	t := strings.TrimSpace(l)
+	if t == "" || !strings.ContainsAny(t[:1], "-*_") {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(t); i++ {
+		switch t[i] {
+		case t[0]:
+			n++
+		case ' ':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+func quoteText(l string) *string {
+	if indentOf(l) > 3 {
+		return nil
+	}
+	t := strings.TrimLeft(l, " ")
+	if !strings.HasPrefix(t, ">") {
+		return nil
+	}
+	t = strings.TrimPrefix(t[1:], " ")
+	return &t
+}
+
+type marker struct {
+	ordered bool
+	start   int
+	indent  int
+	// width — отступ содержимого пункта от начала строки
+	width int
+}
+
+func listMarker(l string) *marker {
+	indent := indentOf(l)
+	if indent > 3 {
+		return nil
+	}
+	t := l[indent:]
+	if isRule(l) {
+		return nil
+	}
+	m := &marker{indent: indent}
+	switch {
+	case len(t) > 0 && strings.ContainsAny(t[:1], "-*+"):
+		m.width = indent + 1
+	default:
+		n := len(t) - len(strings.TrimLeft(t, "0123456789"))
+		if n == 0 || n > 9 || len(t) == n || (t[n] != '.' && t[n] != ')') {
+			return nil
+		}
+		m.ordered = true
+		m.start, _ = strconv.Atoi(t[:n])
+		m.width = indent + n + 1
+	}
+	rest := l[m.width:]
+	switch {
+	case rest == "":
+	case rest[0] == ' ':
+		m.width++
+	default:
+		return nil
+	}
+	return m
+}
+
+// HTML возвращает безопасный HTML-фрагмент.
+func (d *markdownDoc) HTML() string {
+	var b strings.Builder
+	writeBlocks(&b, d.blocks, false)
+	return b.String()
+}
+
+func writeBlocks(b *strings.Builder, blocks []mdBlock, tight bool) {
+	for _, bl := range blocks {
+		switch bl.kind {
+		case blockParagraph:
+			if tight {
+				b.WriteString(paragraphHTML(bl.lines))
+				continue
+			}
+			b.WriteString("<p>" + paragraphHTML(bl.lines) + "</p>\n")
+		case blockHeading:
+			lvl := strconv.Itoa(bl.level)
+			b.WriteString("<h" + lvl + ` id="` + html.EscapeString(bl.id) + `">` + renderInline(bl.lines[0], false) + "</h" + lvl + ">\n")
+		case blockCode:
+			b.WriteString("<pre><code")
+			if bl.lang != "" {
+				b.WriteString(` class="language-` + html.EscapeString(bl.lang) + `"`)
+			}
+			b.WriteString(">")
+			for _, l := range bl.lines {
+				b.WriteString(html.EscapeString(l) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+		case blockQuote:
+			b.WriteString("<blockquote>\n")
+			writeBlocks(b, bl.children, false)
+			b.WriteString("</blockquote>\n")
+		case blockRule:
+			b.WriteString("<hr>\n")
+		case blockList:
+			tag := "ul"
+			if bl.ordered {
+				tag = "ol"
+			}
+			b.WriteString("<" + tag)
+			if bl.ordered && bl.start != 1 {
+				b.WriteString(` start="` + strconv.Itoa(bl.start) + `"`)
+			}
+			b.WriteString(">\n")
+			for _, item := range bl.items {
+				b.WriteString("<li>")
+				// пункт из одного абзаца (и, возможно, вложенного списка) выводится без <p>
+				itemTight := len(item) > 0 && item[0].kind == blockParagraph
+				for _, ib := range item[min(1, len(item)):] {
+					if ib.kind != blockList {
+						itemTight = false
+					}
+				}
+				if itemTight && len(item) > 1 {
+					b.WriteString(paragraphHTML(item[0].lines) + "\n")
+					writeBlocks(b, item[1:], false)
+				} else {
+					writeBlocks(b, item, itemTight)
+				}
+				b.WriteString("</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+		}
+	}
+}
+
+func paragraphHTML(lines []string) string {
+	var b strings.Builder
+	for i, l := range lines {
+		last := i == len(lines)-1
+		brk := !last && (strings.HasSuffix(l, "  ") || strings.HasSuffix(l, "\\"))
+		if brk {
+			l = strings.TrimSuffix(strings.TrimRight(l, " "), "\\")
+		}
+		b.WriteString(renderInline(strings.TrimRight(l, " "), false))
+		switch {
+		case brk:
+			b.WriteString("<br>\n")
+		case !last:
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Outline возвращает заголовки в порядке появления.
+func (d *markdownDoc) Outline() []Heading {
+	var out []Heading
+	var walk func(blocks []mdBlock)
+	walk = func(blocks []mdBlock) {
+		for _, bl := range blocks {
+			switch bl.kind {
+			case blockHeading:
+				out = append(out, Heading{Level: bl.level, Text: renderInline(bl.lines[0], true), ID: bl.id})
+			case blockQuote:
+				walk(bl.children)
+			case blockList:
+				for _, item := range bl.items {
+					walk(item)
+				}
+			}
+		}
+	}
+	walk(d.blocks)
+	return out
+}
+
+// Excerpt — начало текста заметки без разметки (не длиннее n символов).
+// Заголовки и код в него не попадают, если есть обычный текст.
+func (d *markdownDoc) Excerpt(n int) string {
+	var parts, headings []string
+	var walk func(blocks []mdBlock)
+	walk = func(blocks []mdBlock) {
+		for _, bl := range blocks {
+			switch bl.kind {
+			case blockParagraph:
+				parts = append(parts, renderInline(strings.Join(bl.lines, "\n"), true))
+			case blockHeading:
+				headings = append(headings, renderInline(bl.lines[0], true))
+			case blockQuote:
+				walk(bl.children)
+			case blockList:
+				for _, item := range bl.items {
+					walk(item)
+				}
+			}
+		}
+	}
+	walk(d.blocks)
+	if len(parts) == 0 {
+		parts = headings
+	}
+	return truncateText(strings.Join(strings.Fields(strings.Join(parts, " ")), " "), n)
+}
+
+// truncateText обрезает текст по границе слова и добавляет многоточие.
+func truncateText(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)[:n]
+	cut := string(r)
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+// renderInline разбирает строчную разметку; plain == true — только текст без тегов.
+func renderInline(s string, plain bool) string {
+	return renderInlineAt(s, plain, 0)
+}
+
+// renderInlineAt разбирает s внутри depth вложенных ссылок и выделений.
+func renderInlineAt(s string, plain bool, depth int) string {
+	var b strings.Builder
+	nest := depth < maxNesting
+	// unclosed — позиции, начиная с которых закрывающий разделитель уже не нашёлся:
+	// следующим открывающим того же вида искать его заново незачем.
+	unclosed := map[string]int{}
+	// pairs — парные скобки ссылок, считаются при первой '['
+	var pairs []int
+	text := func(t string) {
+		if plain {
+			b.WriteString(t)
+		} else {
+			b.WriteString(html.EscapeString(t))
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!~<>|\"'", s[i+1]) >= 0:
+			text(s[i+1 : i+2])
+			i += 2
+			continue
+
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			fence := s[i : i+n]
+			if from, ok := unclosed[fence]; ok && i+n >= from {
+				text(fence)
+				i += n
+				continue
+			}
+			if end := strings.Index(s[i+n:], fence); end >= 0 {
+				code := s[i+n : i+n+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+					code = code[1 : len(code)-1]
+				}
+				code = strings.ReplaceAll(code, "\n", " ")
+				if plain {
+					b.WriteString(code)
+				} else {
+					b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				}
+				i += n + end + n
+				continue
+			}
+			unclosed[fence] = i + n
+			text(fence)
+			i += n
+			continue
+
+		case nest && c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if pairs == nil {
+				pairs = matchBrackets(s)
+			}
+			if label, dest, n, ok := linkAt(s, i+1, pairs); ok {
+				alt := renderInlineAt(label, true, depth+1)
+				if u, safe := safeURL(dest, true); safe && !plain {
+					b.WriteString(`<img src="` + html.EscapeString(u) + `" alt="` + html.EscapeString(alt) + `">`)
+				} else {
+					text(alt)
+				}
+				i += 1 + n
+				continue
+			}
+
+		case nest && c == '[':
+			if pairs == nil {
+				pairs = matchBrackets(s)
+			}
+			if label, dest, n, ok := linkAt(s, i, pairs); ok {
+				inner := renderInlineAt(label, plain, depth+1)
+				if u, safe := safeURL(dest, false); safe && !plain {
+					b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + inner + "</a>")
+				} else {
+					b.WriteString(inner)
+				}
+				i += n
+				continue
+			}
+
+		case c == '<':
+			// в адресе нет пробелов и '<', поэтому дальше первого из них '>' не ищем
+			if end := strings.IndexAny(s[i+1:], "<> \n") + 1; end > 1 && s[i+end] == '>' {
+				dest := s[i+1 : i+end]
+				if strings.Contains(dest, ":") {
+					if u, safe := safeURL(dest, false); safe {
+						if plain {
+							b.WriteString(dest)
+						} else {
+							b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + html.EscapeString(dest) + "</a>")
+						}
+						i += end + 1
+						continue
+					}
+				}
+			}
+
+		case nest && (c == '*' || c == '_' || c == '~'):
+			if tag, inner, n, ok := emphasisAt(s, i, unclosed); ok {
+				if plain {
+					b.WriteString(renderInlineAt(inner, true, depth+1))
+				} else {
+					b.WriteString("<" + tag + ">" + renderInlineAt(inner, false, depth+1) + "</" + tag + ">")
+				}
+				i += n
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		text(s[i : i+size])
+		i += size
+	}
+	return b.String()
+}
+
+// linkAt разбирает [текст](адрес "заголовок") с позиции i и возвращает длину конструкции.
+func linkAt(s string, i int, pairs []int) (label, dest string, n int, ok bool) {
+	closeAt := pairs[i]
+	if closeAt < 0 || closeAt+1 >= len(s) || s[closeAt+1] != '(' {
+		return "", "", 0, false
+	}
+	end := pairs[closeAt+1]
+	if end < 0 {
+		return "", "", 0, false
+	}
+	dest = strings.TrimSpace(s[closeAt+2 : end])
+	if f := strings.Fields(dest); len(f) > 0 {
+		dest = f[0]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return s[i+1 : closeAt], dest, end + 1 - i, true
+}
+
+// matchBrackets находит за один проход парные скобки: pairs[i] — позиция скобки,
+// закрывающей '[' или '(' в позиции i, или -1. Экранирование учитывается только у [].
+func matchBrackets(s string) []int {
+	pairs := make([]int, len(s))
+	var square, paren []int
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		pairs[i] = -1
+		switch c := s[i]; {
+		case c == '(':
+			paren = append(paren, i)
+		case c == ')' && len(paren) > 0:
+			pairs[paren[len(paren)-1]] = i
+			paren = paren[:len(paren)-1]
+		case escaped:
+		case c == '[':
+			square = append(square, i)
+		case c == ']' && len(square) > 0:
+			pairs[square[len(square)-1]] = i
+			square = square[:len(square)-1]
+		}
+		escaped = !escaped && s[i] == '\\'
+	}
+	return pairs
+}
+
+// emphasisAt ищет парный разделитель для **, __, *, _ или ~~ в позиции i.
+// Неудачный поиск запоминается в unclosed: закрывающий подходит любому открывающему
+// до него, поэтому для открывающих дальше по строке его тоже нет.
+func emphasisAt(s string, i int, unclosed map[string]int) (tag, inner string, n int, ok bool) {
+	c := s[i]
+	delim := string(c)
+	switch {
+	case strings.HasPrefix(s[i:], strings.Repeat(delim, 2)):
+		delim = strings.Repeat(delim, 2)
+		tag = "strong"
+		if c == '~' {
+			tag = "del"
+		}
+	case c == '~':
+		return "", "", 0, false
+	default:
+		tag = "em"
+	}
+
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+		return "", "", 0, false
+	}
+	// _ внутри слова (snake_case) не считается разметкой
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", "", 0, false
+	}
+	if from, seen := unclosed[delim]; seen && start >= from {
+		return "", "", 0, false
+	}
+
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if s[j] == '`' {
+			// разделители внутри кода не учитываются
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || s[j-1] == ' ' || s[j-1] == '\n' {
+			continue
+		}
+		// *a **b** c*: одиночный разделитель не закрывается половиной двойного
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == c {
+			j++
+			continue
+		}
+		if c == '_' && j+1 < len(s) && isWordByte(s[j+1]) {
+			continue
+		}
+		return tag, s[start:j], j + len(delim) - i, true
+	}
+	unclosed[delim] = start
+	return "", "", 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c >= utf8.RuneSelf || c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// safeURL пропускает только относительные адреса и схемы http, https и (для ссылок) mailto.
+func safeURL(raw string, image bool) (string, bool) {
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https":
+	case "mailto":
+		if image {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
+
+// Представления GET /notes/{id}: JSON с полем html или сам HTML-фрагмент.
+const (
+	renderNone = iota
+	renderField
+	renderPage
+)
+
+// renderFormat выбирает представление по ?render=html, затем по заголовку Accept
+// (text/html раньше application/json).
+func renderFormat(req *http.Request) (int, error) {
+	switch req.URL.Query().Get("render") {
+	case "html":
+		return renderField, nil
+	case "":
+	default:
+		return renderNone, queryError("invalid_render")
+	}
+
+	for _, part := range strings.Split(req.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case "text/html":
+			return renderPage, nil
+		case "application/json", "*/*":
+			return renderNone, nil
+		}
+	}
+	return renderNone, nil
+}
+
+func writeHTML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// ссылки и картинки уже отфильтрованы, CSP — дополнительная защита при открытии в браузере
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self' http: https:")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, body)
+}
+
+// summarize заполняет Excerpt и Outline для списка заметок по первым summaryScanBytes
+// каждой: оглавление длинной заметки содержит только заголовки из этого начала.
+func summarize(notes []Note) {
+	for i := range notes {
+		d := parseMarkdown(summaryPrefix(notes[i].Content))
+		notes[i].Excerpt = d.Excerpt(excerptLen)
+		notes[i].Outline = d.Outline()
+	}
+}
+
+// summaryPrefix обрезает текст до summaryScanBytes по последнему целому переводу строки
+// или, если строка одна, по границе символа.
+func summaryPrefix(s string) string {
+	if len(s) <= summaryScanBytes {
+		return s
+	}
+	if i := strings.LastIndexByte(s[:summaryScanBytes], '\n'); i > 0 {
+		return s[:i]
+	}
+	i := summaryScanBytes
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i]
+}
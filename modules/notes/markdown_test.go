@@ -0,0 +1,205 @@
+package notes
+
+import (
+	"html"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestMarkdownHTML(t *testing.T) {
+	cases := []struct {
+		name, src, want string
+	}{
+		{"heading", "## Раздел ##", `<h2 id="раздел">Раздел</h2>` + "\n"},
+		{"setext", "Title\n=====", `<h1 id="title">Title</h1>` + "\n"},
+		{"inline", "*a* **b** ~~c~~ `<d>`", "<p><em>a</em> <strong>b</strong> <del>c</del> <code>&lt;d&gt;</code></p>\n"},
+		{"snake case", "snake_case_name", "<p>snake_case_name</p>\n"},
+		{"escape", `\*not em\*`, "<p>*not em*</p>\n"},
+		{"raw html", `<img src=x onerror="alert(1)">`, "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n"},
+		{"link", `[site](https://example.com/?a=1&b=2 "t")`,
+			`<p><a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener noreferrer">site</a></p>` + "\n"},
+		{"unsafe link", "[x](javascript:alert(1)) [y](JavaScript:alert(1))", "<p>x y</p>\n"},
+		{"image", "![a \"b\"](/p.png) ![c](data:image/png;base64,AA)", `<p><img src="/p.png" alt="a &#34;b&#34;"> c</p>` + "\n"},
+		{"autolink", "<mailto:a@b.c> <foo>", `<p><a href="mailto:a@b.c" rel="nofollow noopener noreferrer">mailto:a@b.c</a> &lt;foo&gt;</p>` + "\n"},
+		{"break", "a  \nb\nc", "<p>a<br>\nb\nc</p>\n"},
+		{"code block", "```js\n<b>\n```", `<pre><code class="language-js">&lt;b&gt;` + "\n</code></pre>\n"},
+		{"rule", "a\n\n* * *", "<p>a</p>\n<hr>\n"},
+		{"quote", "> q\n> more", "<blockquote>\n<p>q\nmore</p>\n</blockquote>\n"},
+		{"list", "- a\n- b\n  - c\n\n2. x\n3. y",
+			"<ul>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul>\n</li>\n</ul>\n<ol start=\"2\">\n<li>x</li>\n<li>y</li>\n</ol>\n"},
+		{"loose item", "- a\n\n  second", "<ul>\n<li><p>a</p>\n<p>second</p>\n</li>\n</ul>\n"},
+		{"nested brackets", "[a [b] c](/x) [[d](/y)", `<p><a href="/x" rel="nofollow noopener noreferrer">a [b] c</a> [<a href="/y" rel="nofollow noopener noreferrer">d</a></p>` + "\n"},
+		{"unclosed emphasis", "*a *b* c *d", "<p><em>a *b</em> c *d</p>\n"},
+	}
+	for _, c := range cases {
+		if got := parseMarkdown(c.src).HTML(); got != c.want {
+			t.Errorf("%s:\n got %q\nwant %q", c.name, got, c.want)
+		}
+	}
+}
+
+// Разметка глубже maxNesting остаётся текстом, а не разбирается уровень за уровнем.
+func TestMarkdownNestingLimit(t *testing.T) {
+	list := parseMarkdown(strings.Repeat("- ", 1000) + "a").HTML()
+	if n := strings.Count(list, "<ul>"); n != maxNesting {
+		t.Fatalf("list depth: got %d, want %d", n, maxNesting)
+	}
+	quote := parseMarkdown(strings.Repeat(">", 1000) + " a").HTML()
+	if n := strings.Count(quote, "<blockquote>"); n != maxNesting {
+		t.Fatalf("quote depth: got %d, want %d", n, maxNesting)
+	}
+	links := parseMarkdown(strings.Repeat("[", 1000) + "a" + strings.Repeat("](/x)", 1000)).HTML()
+	if n := strings.Count(links, "<a "); n != maxNesting {
+		t.Fatalf("link depth: got %d, want %d", n, maxNesting)
+	}
+}
+
+func TestMarkdownOutline(t *testing.T) {
+	d := parseMarkdown("# Intro\ntext\n## Setup *fast*\n> ### Quoted\n## Setup fast\n```\n# not a heading\n```")
+	got := d.Outline()
+	want := []Heading{
+		{1, "Intro", "intro"},
+		{2, "Setup fast", "setup-fast"},
+		{3, "Quoted", "quoted"},
+		{2, "Setup fast", "setup-fast-1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("outline: got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("outline[%d]: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if html := d.HTML(); !strings.Contains(html, `<h2 id="setup-fast-1">`) {
+		t.Fatalf("heading ids do not match outline: %s", html)
+	}
+}
+
+func TestMarkdownExcerpt(t *testing.T) {
+	d := parseMarkdown("# Title\n\nSome **bold** [link](http://x) and `code`.\n\n```\nskipped\n```\n\n- item")
+	if got := d.Excerpt(excerptLen); got != "Some bold link and code. item" {
+		t.Fatalf("excerpt: %q", got)
+	}
+	if got := parseMarkdown("# Only heading").Excerpt(excerptLen); got != "Only heading" {
+		t.Fatalf("heading-only excerpt: %q", got)
+	}
+	long := parseMarkdown(strings.Repeat("слово ", 100)).Excerpt(20)
+	if long != "слово слово слово…" {
+		t.Fatalf("truncated excerpt: %q", long)
+	}
+}
+
+func TestRenderFormat(t *testing.T) {
+	cases := []struct {
+		url, accept string
+		want        int
+	}{
+		{"/notes/1", "", renderNone},
+		{"/notes/1", "application/json", renderNone},
+		{"/notes/1", "text/html,application/xhtml+xml,*/*;q=0.8", renderPage},
+		{"/notes/1", "*/*", renderNone},
+		{"/notes/1?render=html", "text/html", renderField},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.url, nil)
+		req.Header.Set("Accept", c.accept)
+		if got, err := renderFormat(req); err != nil || got != c.want {
+			t.Fatalf("%s %q: got %d, %v; want %d", c.url, c.accept, got, err, c.want)
+		}
+	}
+	if _, err := renderFormat(httptest.NewRequest("GET", "/notes/1?render=pdf", nil)); err == nil {
+		t.Fatal("expected invalid_render")
+	}
+}
+
+func TestSummaryPrefix(t *testing.T) {
+	if s := "# a\n\nshort"; summaryPrefix(s) != s {
+		t.Fatal("short note must be kept whole")
+	}
+	lines := strings.Repeat("line\n", summaryScanBytes/5+10)
+	if got := summaryPrefix(lines); len(got) > summaryScanBytes || !strings.HasSuffix(got, "line") {
+		t.Fatalf("must cut at a newline within the limit: len %d, tail %q", len(got), got[len(got)-8:])
+	}
+	runes := strings.Repeat("я", summaryScanBytes)
+	if got := summaryPrefix(runes); len(got) > summaryScanBytes || !utf8.ValidString(got) {
+		t.Fatalf("must cut at a rune boundary: len %d", len(got))
+	}
+
+	notes := []Note{{Content: "# Top\n\n" + lines + "\n# Tail"}}
+	summarize(notes)
+	if len(notes[0].Outline) != 1 || notes[0].Outline[0].Text != "Top" {
+		t.Fatalf("outline must cover only the scanned prefix: %+v", notes[0].Outline)
+	}
+}
+
+// fuzzTag — тег, который умеет выводить рендерер; всё прочее со знаком < — ошибка экранирования.
+var fuzzTag = regexp.MustCompile(`^<(/?)(p|h[1-6]|pre|code|blockquote|hr|ul|ol|li|br|img|a|em|strong|del)((?: [a-z]+="[^"<>]*")*)>`)
+
+var fuzzAttr = regexp.MustCompile(` ([a-z]+)="([^"]*)"`)
+
+// unsafeURL повторяет разбор браузера: пробелы и управляющие символы по краям
+// отбрасываются, табы и переводы строк внутри удаляются, затем смотрится схема.
+func unsafeURL(raw string) bool {
+	v := strings.TrimFunc(html.UnescapeString(raw), func(r rune) bool { return r <= ' ' })
+	v = strings.NewReplacer("\t", "", "\n", "", "\r", "").Replace(v)
+	u, err := url.Parse(v)
+	if err != nil {
+		return true
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return false
+	}
+	return true
+}
+
+func checkRenderedHTML(t *testing.T, out string) {
+	t.Helper()
+	for i := strings.IndexByte(out, '<'); i >= 0; i = strings.IndexByte(out, '<') {
+		out = out[i:]
+		m := fuzzTag.FindStringSubmatch(out)
+		if m == nil {
+			t.Fatalf("unescaped < in output: %q", out[:min(len(out), 80)])
+		}
+		for _, a := range fuzzAttr.FindAllStringSubmatch(m[3], -1) {
+			if (a[1] == "href" || a[1] == "src") && unsafeURL(a[2]) {
+				t.Fatalf("unsafe %s in output: %q", a[1], m[0])
+			}
+		}
+		out = out[len(m[0]):]
+	}
+}
+
+func FuzzRender(f *testing.F) {
+	for _, s := range []string{
+		"# T\n\n*a* **b** ~~c~~ `d`",
+		`<img src=x onerror="alert(1)">`,
+		"[x](javascript:alert(1)) [y](<JavaScript:alert(1)>) ![z](data:image/png;base64,AA)",
+		"[a [b] c](/x \"t\") <mailto:a@b.c> <https://e.x/?a=1&b=2>",
+		"> q\n> - a\n>   1. b\n\n```js\n<b>\n```\n\n* * *",
+		strings.Repeat("[", 200) + strings.Repeat("*a", 200),
+		strings.Repeat("> - ", 100) + "x",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		if !utf8.ValidString(src) {
+			return
+		}
+		start := time.Now()
+		d := parseMarkdown(src)
+		out := d.HTML()
+		d.Outline()
+		d.Excerpt(excerptLen)
+		if el := time.Since(start); el > time.Second {
+			t.Fatalf("render of %d bytes took %s", len(src), el)
+		}
+		checkRenderedHTML(t, out)
+	})
+}
@@ -0,0 +1,18 @@
+package notes
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations — схема модуля: таблицы notes, note_revisions, notebooks и tags.
+func (m *Module) Migrations() fs.FS {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
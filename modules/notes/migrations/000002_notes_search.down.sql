@@ -0,0 +1,3 @@
+drop index if exists idx_notes_search;
+
+alter table notes drop column if exists search;
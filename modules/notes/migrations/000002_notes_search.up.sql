@@ -0,0 +1,8 @@
+alter table notes
+  add column if not exists search tsvector
+  generated always as (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+  ) stored;
+
+create index if not exists idx_notes_search on notes using gin (search);
@@ -0,0 +1,3 @@
+drop index if exists idx_notes_deleted_at;
+
+alter table notes drop column if exists deleted_at;
@@ -0,0 +1,3 @@
+alter table notes add column if not exists deleted_at timestamptz;
+
+create index if not exists idx_notes_deleted_at on notes (deleted_at) where deleted_at is not null;
@@ -0,0 +1,10 @@
+create table if not exists note_revisions (
+  note_id bigint not null references notes (id) on delete cascade,
+  rev integer not null,
+  title text not null,
+  content text not null,
+  action text not null,
+  author text,
+  created_at timestamptz not null default now(),
+  primary key (note_id, rev)
+);
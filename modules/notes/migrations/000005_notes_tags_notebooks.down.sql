@@ -0,0 +1,7 @@
+drop index if exists idx_notes_notebook_id;
+
+alter table notes drop column if exists notebook_id;
+
+drop table if exists note_tags;
+drop table if exists tags;
+drop table if exists notebooks;
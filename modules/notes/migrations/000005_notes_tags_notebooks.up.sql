@@ -0,0 +1,23 @@
+create table if not exists notebooks (
+  id bigserial primary key,
+  name text not null unique,
+  created_at timestamptz not null default now()
+);
+
+create table if not exists tags (
+  id bigserial primary key,
+  name text not null unique,
+  created_at timestamptz not null default now()
+);
+
+create table if not exists note_tags (
+  note_id bigint not null references notes (id) on delete cascade,
+  tag_id bigint not null references tags (id) on delete cascade,
+  primary key (note_id, tag_id)
+);
+
+create index if not exists idx_note_tags_tag_id on note_tags (tag_id);
+
+alter table notes add column if not exists notebook_id bigint references notebooks (id) on delete set null;
+
+create index if not exists idx_notes_notebook_id on notes (notebook_id);
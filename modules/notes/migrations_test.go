@@ -0,0 +1,38 @@
+package notes
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+func TestMigrations(t *testing.T) {
+	src, err := iofs.New(New().Migrations(), ".")
+	if err != nil {
+		t.Fatalf("source: %v", err)
+	}
+	defer src.Close()
+
+	v, err := src.First()
+	if err != nil || v != 1 {
+		t.Fatalf("first version = %d, %v", v, err)
+	}
+	// у каждой миграции есть откат
+	for {
+		r, _, err := src.ReadDown(v)
+		if err != nil {
+			t.Fatalf("version %d has no down migration: %v", v, err)
+		}
+		_ = r.Close()
+
+		v, err = src.Next(v)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next after %d: %v", v, err)
+		}
+	}
+}
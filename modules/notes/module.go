@@ -3,33 +3,89 @@ package notes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/Illusiard/miniapi/internal/caps"
 	"github.com/Illusiard/miniapi/internal/meta"
 )
 
-type Module struct{}
+type Module struct {
+	opts options
+	s    caps.Setup
+}
+
+type options struct {
+	trashRetention time.Duration
+	purgeInterval  time.Duration
+}
+
+type Option func(*options)
+
+// WithTrashRetention задаёт, сколько заметка хранится в корзине до фоновой очистки.
+// Ноль отключает фоновую очистку.
+func WithTrashRetention(d time.Duration) Option {
+	return func(o *options) { o.trashRetention = d }
+}
+
+// WithPurgeInterval задаёт период фоновой очистки корзины.
+func WithPurgeInterval(d time.Duration) Option {
+	return func(o *options) { o.purgeInterval = d }
+}
+
+func New(opts ...Option) *Module {
+	m := &Module{opts: options{purgeInterval: time.Hour}}
+	for _, opt := range opts {
+		opt(&m.opts)
+	}
+	return m
+}
 
-func New() *Module { return &Module{} }
 func (m *Module) Name() string { return "notes" }
 
 type Note struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	NotebookID *int64   `json:"notebookId,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+
+	// HTML — отрендеренный content (GET /notes/{id}?render=html),
+	// Excerpt и Outline заполняются в списке заметок.
+	HTML    string    `json:"html,omitempty"`
+	Excerpt string    `json:"excerpt,omitempty"`
+	Outline []Heading `json:"outline,omitempty"`
+}
+
+// noteColumns — колонки Note в порядке noteDest; table — имя или алиас таблицы notes.
+func noteColumns(table string) string {
+	return table + `.id, ` + table + `.title, ` + table + `.content, ` + table + `.created_at, ` + table + `.updated_at, ` +
+		table + `.notebook_id, array(
+			select t.name from note_tags nt join tags t on t.id = nt.tag_id
+			where nt.note_id = ` + table + `.id
+			order by t.name
+		) as tags`
+}
+
+func noteDest(n *Note) []any {
+	return []any{&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt, &n.NotebookID, &n.Tags}
 }
 
 type createReq struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	NotebookID *int64   `json:"notebookId,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
 }
 
 type updateReq struct {
@@ -37,10 +93,22 @@ type updateReq struct {
 	Content string `json:"content"`
 }
 
+// maxContentBytes — предельный размер текста заметки: Markdown разбирается
+// при каждой выдаче списка.
+const maxContentBytes = 256 << 10
+
+func checkContent(content string) error {
+	if len(content) > maxContentBytes {
+		return queryError("content_too_long")
+	}
+	return nil
+}
+
 func (m *Module) Register(s caps.Setup) error {
 	if s.Store == nil {
 		return errConfig("notes module requires Store capability")
 	}
+	m.s = s
 
 	s.Meta.AddEntity(meta.Entity{
 		Name:   "Note",
@@ -52,18 +120,44 @@ func (m *Module) Register(s caps.Setup) error {
 			{Name: "content", Type: "string", Nullable: false},
 			{Name: "created_at", Type: "datetime", Nullable: false},
 			{Name: "updated_at", Type: "datetime", Nullable: false},
+			{Name: "deleted_at", Type: "datetime", Nullable: true},
+			{Name: "notebook_id", Type: "int", Nullable: true},
+		},
+		Relations: []meta.Relation{
+			{Name: "notebook", Kind: meta.BelongsTo, Entity: "Notebook", Field: "notebook_id"},
+			{Name: "tags", Kind: meta.ManyToMany, Entity: "Tag", Through: "note_tags"},
 		},
 	})
+	tagKind.register(s, m.Name())
+	notebookKind.register(s, m.Name())
 
 	s.Routes.Route("/notes", func(r caps.Routes) {
 		r.Get("/", func(w http.ResponseWriter, req *http.Request) {
-			notes, err := listNotes(req.Context(), s)
+			q, err := parseListQuery(req.URL.Query())
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			notes, err := listNotes(req.Context(), s, q)
 			if err != nil {
+				s.Log.ErrorContext(req.Context(), "list notes failed", "error", err)
 				writeError(w, http.StatusInternalServerError, "list_failed")
 				return
 			}
-			writeJSON(w, http.StatusOK, notes)
-		})
+			summarize(notes)
+			writeList(w, req, q, notes)
+		}, caps.Summary("List notes with plain-text excerpt and heading outline: without list parameters returns a plain array (max 100), "+
+			"with any of limit, offset or cursor, sort, title, createdFrom, createdTo, tag, notebook a page envelope; other query parameters keep the shape"),
+			caps.Returns(http.StatusOK, listPage{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/search", handleSearch(s),
+			caps.Summary("Full-text search over title and content (q uses websearch syntax), ranked, with highlighted snippets; "+
+				"supports limit, offset or cursor"),
+			caps.Returns(http.StatusOK, searchPage{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/trash", handleTrash(s),
+			caps.Summary("List soft-deleted notes, most recently deleted first; supports limit and offset"),
+			caps.Returns(http.StatusOK, listPage{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusInternalServerError, nil))
 
 		r.Post("/", func(w http.ResponseWriter, req *http.Request) {
 			var in createReq
@@ -75,22 +169,59 @@ func (m *Module) Register(s caps.Setup) error {
 				writeError(w, http.StatusBadRequest, "title_and_content_required")
 				return
 			}
+			if err := checkContent(in.Content); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			tags, err := normalizeTags(in.Tags)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 
-			n, err := createNote(req.Context(), s, in.Title, in.Content)
+			n, err := createNote(req.Context(), s, in.Title, in.Content, in.NotebookID, tags)
+			if pgCode(err) == pgForeignKeyViolation {
+				writeError(w, http.StatusBadRequest, "invalid_notebook")
+				return
+			}
 			if err != nil {
+				s.Log.ErrorContext(req.Context(), "create note failed", "error", err)
 				writeError(w, http.StatusInternalServerError, "create_failed")
 				return
 			}
+			w.Header().Set("ETag", etagOf(n))
 			writeJSON(w, http.StatusCreated, n)
-		})
+		}, caps.Summary("Create note"), caps.Accepts(createReq{}),
+			caps.Returns(http.StatusCreated, Note{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/export", handleExport(s),
+			caps.Summary("Stream all notes (except trash) as NDJSON or CSV, chosen by ?format=ndjson|csv or Accept"),
+			caps.Returns(http.StatusOK, ""), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Post("/import", handleImport(s),
+			caps.Summary("Load notes from NDJSON or CSV (?format= or Content-Type) with COPY; invalid rows are rejected and reported"),
+			caps.Returns(http.StatusOK, importSummary{}), caps.Returns(http.StatusBadRequest, nil),
+			caps.Returns(http.StatusRequestEntityTooLarge, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Post("/bulk", handleBulk(s),
+			caps.Summary("Run create/update/delete operations in one transaction; ?mode=atomic (default) rolls back on the first failure, "+
+				"?mode=partial reports status and error code per operation"),
+			caps.Accepts([]bulkOp{}), caps.Returns(http.StatusOK, bulkResponse{}), caps.Returns(http.StatusUnprocessableEntity, bulkResponse{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusInternalServerError, nil))
 
 		r.Get("/{id}", func(w http.ResponseWriter, req *http.Request) {
 			id, ok := parseID(w, req)
 			if !ok {
 				return
 			}
+			render, err := renderFormat(req)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 			n, found, err := getNote(req.Context(), s, id)
 			if err != nil {
+				s.Log.ErrorContext(req.Context(), "get note failed", "error", err)
 				writeError(w, http.StatusInternalServerError, "get_failed")
 				return
 			}
@@ -98,8 +229,28 @@ func (m *Module) Register(s caps.Setup) error {
 				writeError(w, http.StatusNotFound, "not_found")
 				return
 			}
+			etag := etagOf(n)
+			if render == renderPage {
+				etag = pageETag(n)
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Vary", "Accept")
+			if inm := req.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			switch render {
+			case renderPage:
+				writeHTML(w, parseMarkdown(n.Content).HTML())
+				return
+			case renderField:
+				n.HTML = parseMarkdown(n.Content).HTML()
+			}
 			writeJSON(w, http.StatusOK, n)
-		})
+		}, caps.Summary("Get note (ETag, honors If-None-Match); ?render=html adds sanitized HTML of the Markdown content, "+
+			"Accept: text/html returns the HTML itself"),
+			caps.Returns(http.StatusOK, Note{}), caps.Returns(http.StatusNotModified, nil),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
 
 		r.Put("/{id}", func(w http.ResponseWriter, req *http.Request) {
 			id, ok := parseID(w, req)
@@ -115,9 +266,18 @@ func (m *Module) Register(s caps.Setup) error {
 				writeError(w, http.StatusBadRequest, "title_and_content_required")
 				return
 			}
+			if err := checkContent(in.Content); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
 
-			n, found, err := updateNote(req.Context(), s, id, in.Title, in.Content)
+			n, found, err := updateNote(req.Context(), s, id, in.Title, in.Content, changeOf(req))
+			if errors.Is(err, errPreconditionFailed) {
+				writeError(w, http.StatusPreconditionFailed, "precondition_failed")
+				return
+			}
 			if err != nil {
+				s.Log.ErrorContext(req.Context(), "update note failed", "error", err)
 				writeError(w, http.StatusInternalServerError, "update_failed")
 				return
 			}
@@ -125,16 +285,31 @@ func (m *Module) Register(s caps.Setup) error {
 				writeError(w, http.StatusNotFound, "not_found")
 				return
 			}
+			w.Header().Set("ETag", etagOf(n))
 			writeJSON(w, http.StatusOK, n)
-		})
+		}, caps.Summary("Update note (honors If-Match)"), caps.Accepts(updateReq{}), caps.Returns(http.StatusOK, Note{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusPreconditionFailed, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Patch("/{id}", handlePatch(s),
+			caps.Summary("Partially update note: application/merge-patch+json (RFC 7396) or application/json-patch+json (RFC 6902); honors If-Match"),
+			caps.Accepts(mergePatchReq{}), caps.Returns(http.StatusOK, Note{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusConflict, nil),
+			caps.Returns(http.StatusPreconditionFailed, nil), caps.Returns(http.StatusUnsupportedMediaType, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
 
 		r.Delete("/{id}", func(w http.ResponseWriter, req *http.Request) {
 			id, ok := parseID(w, req)
 			if !ok {
 				return
 			}
-			found, err := deleteNote(req.Context(), s, id)
+			found, err := deleteNote(req.Context(), s, id, changeOf(req))
+			if errors.Is(err, errPreconditionFailed) {
+				writeError(w, http.StatusPreconditionFailed, "precondition_failed")
+				return
+			}
 			if err != nil {
+				s.Log.ErrorContext(req.Context(), "delete note failed", "error", err)
 				writeError(w, http.StatusInternalServerError, "delete_failed")
 				return
 			}
@@ -143,7 +318,46 @@ func (m *Module) Register(s caps.Setup) error {
 				return
 			}
 			w.WriteHeader(http.StatusNoContent)
-		})
+		}, caps.Summary("Move note to trash (honors If-Match)"), caps.Returns(http.StatusNoContent, nil),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusPreconditionFailed, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Post("/{id}/restore", handleRestore(s),
+			caps.Summary("Restore note from trash"), caps.Returns(http.StatusOK, Note{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Delete("/{id}/purge", handlePurge(s),
+			caps.Summary("Permanently delete note, whether trashed or not"), caps.Returns(http.StatusNoContent, nil),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Put("/{id}/tags", handleSetTags(s),
+			caps.Summary("Replace note tags; unknown tags are created (honors If-Match)"), caps.Accepts(noteTagsReq{}),
+			caps.Returns(http.StatusOK, Note{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil),
+			caps.Returns(http.StatusPreconditionFailed, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Put("/{id}/notebook", handleSetNotebook(s),
+			caps.Summary("Move note to a notebook, null removes it from its notebook (honors If-Match)"), caps.Accepts(noteNotebookReq{}),
+			caps.Returns(http.StatusOK, Note{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil),
+			caps.Returns(http.StatusPreconditionFailed, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/{id}/revisions", handleRevisions(s),
+			caps.Summary("List note revisions (states before each change), newest first; supports limit and offset"),
+			caps.Returns(http.StatusOK, revisionPage{}), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/{id}/revisions/{rev}", handleRevision(s),
+			caps.Summary("Get note revision"), caps.Returns(http.StatusOK, Revision{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Get("/{id}/revisions/{rev}/diff", handleRevisionDiff(s),
+			caps.Summary("Line diff (text/plain) between the revision and ?to=<rev>, by default the current note"),
+			caps.Returns(http.StatusOK, ""), caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
+
+		r.Post("/{id}/revisions/{rev}/restore", handleRollback(s),
+			caps.Summary("Roll note back to the revision (honors If-Match)"), caps.Returns(http.StatusOK, Note{}),
+			caps.Returns(http.StatusBadRequest, nil), caps.Returns(http.StatusNotFound, nil), caps.Returns(http.StatusPreconditionFailed, nil),
+			caps.Returns(http.StatusInternalServerError, nil))
 	})
 
 	return nil
@@ -163,114 +377,117 @@ func parseID(w http.ResponseWriter, req *http.Request) (int64, bool) {
 	return id, true
 }
 
-func listNotes(ctx context.Context, s caps.Setup) ([]Note, error) {
-	out := make([]Note, 0, 16)
-
-	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
-		rows, err := tx.Query(ctx, `
-			select id, title, content, created_at, updated_at
-			from notes
-			order by id desc
-			limit 100
-		`)
-		if err != nil {
-			return err
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var n Note
-			if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt); err != nil {
-				return err
-			}
-			out = append(out, n)
-		}
-		return rows.Err()
-	})
-
-	return out, err
-}
-
 func getNote(ctx context.Context, s caps.Setup, id int64) (Note, bool, error) {
 	var n Note
-	var found bool
+	err := s.Store.QueryRow(ctx, `
+		select `+noteColumns("notes")+`
+		from notes
+		where id = $1 and deleted_at is null
+	`, id).Scan(noteDest(&n)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Note{}, false, nil
+	}
+	return n, err == nil, err
+}
 
-	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
-		row := tx.QueryRow(ctx, `
-			select id, title, content, created_at, updated_at
-			from notes
-			where id = $1
-		`, id)
-
-		if err := row.Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt); err != nil {
-			if err == pgx.ErrNoRows {
-				found = false
-				return nil
-			}
-			return err
-		}
-		found = true
-		return nil
+// collectNotes читает заметки, выбранные через noteColumns, из результата Query.
+func collectNotes(rows pgx.Rows, err error) ([]Note, error) {
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Note, error) {
+		var n Note
+		err := row.Scan(noteDest(&n)...)
+		return n, err
 	})
-
-	return n, found, err
 }
 
-func createNote(ctx context.Context, s caps.Setup, title, content string) (Note, error) {
+func createNote(ctx context.Context, s caps.Setup, title, content string, notebookID *int64, tags []string) (Note, error) {
 	var n Note
-
 	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
-		row := tx.QueryRow(ctx, `
-			insert into notes(title, content)
-			values($1, $2)
-			returning id, title, content, created_at, updated_at
-		`, title, content)
-
-		return row.Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt)
+		var err error
+		n, err = insertNote(ctx, tx, title, content, notebookID, tags)
+		return err
 	})
+	return n, err
+}
 
+func insertNote(ctx context.Context, tx pgx.Tx, title, content string, notebookID *int64, tags []string) (Note, error) {
+	var n Note
+	row := tx.QueryRow(ctx, `
+		insert into notes(title, content, notebook_id)
+		values($1, $2, $3)
+		returning `+noteColumns("notes")+`
+	`, title, content, notebookID)
+
+	if err := row.Scan(noteDest(&n)...); err != nil || len(tags) == 0 {
+		return n, err
+	}
+	if err := setNoteTags(ctx, tx, n.ID, tags); err != nil {
+		return Note{}, err
+	}
+	err := tx.QueryRow(ctx, `select `+noteColumns("notes")+` from notes where id = $1`, n.ID).Scan(noteDest(&n)...)
 	return n, err
 }
 
-func updateNote(ctx context.Context, s caps.Setup, id int64, title, content string) (Note, bool, error) {
+func updateNote(ctx context.Context, s caps.Setup, id int64, title, content string, c change) (Note, bool, error) {
 	var n Note
 	var found bool
 
 	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
-		row := tx.QueryRow(ctx, `
-			update notes
-			set title = $2,
-			    content = $3,
-			    updated_at = now()
-			where id = $1
-			returning id, title, content, created_at, updated_at
-		`, id, title, content)
-
-		if err := row.Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt, &n.UpdatedAt); err != nil {
-			if err == pgx.ErrNoRows {
-				found = false
-				return nil
-			}
-			return err
-		}
-		found = true
-		return nil
+		var err error
+		n, found, err = updateNoteTx(ctx, tx, id, title, content, c)
+		return err
 	})
 
 	return n, found, err
 }
 
-func deleteNote(ctx context.Context, s caps.Setup, id int64) (bool, error) {
-	var rows int64
-	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
-		tag, err := tx.Exec(ctx, `delete from notes where id = $1`, id)
-		if err != nil {
-			return err
+func updateNoteTx(ctx context.Context, tx pgx.Tx, id int64, title, content string, c change) (Note, bool, error) {
+	if _, exists, err := c.begin(ctx, tx, id, actionUpdate); err != nil || !exists {
+		return Note{}, false, err
+	}
+
+	var n Note
+	row := tx.QueryRow(ctx, `
+		update notes
+		set title = $2,
+		    content = $3,
+		    updated_at = now()
+		where id = $1 and deleted_at is null
+		returning `+noteColumns("notes")+`
+	`, id, title, content)
+
+	if err := row.Scan(noteDest(&n)...); err != nil {
+		if err == pgx.ErrNoRows {
+			return Note{}, false, nil
 		}
-		rows = tag.RowsAffected()
-		return nil
+		return Note{}, false, err
+	}
+	return n, true, nil
+}
+
+func deleteNote(ctx context.Context, s caps.Setup, id int64, c change) (bool, error) {
+	var found bool
+	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		found, err = deleteNoteTx(ctx, tx, id, c)
+		return err
 	})
-	return rows > 0, err
+	return found, err
+}
+
+// deleteNoteTx переносит заметку в корзину.
+func deleteNoteTx(ctx context.Context, tx pgx.Tx, id int64, c change) (bool, error) {
+	if _, exists, err := c.begin(ctx, tx, id, actionDelete); err != nil || !exists {
+		return false, err
+	}
+
+	tag, err := tx.Exec(ctx, `update notes set deleted_at = now() where id = $1 and deleted_at is null`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -283,6 +500,19 @@ func writeError(w http.ResponseWriter, status int, code string) {
 	writeJSON(w, status, map[string]string{"error": code})
 }
 
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
 type errConfig string
 
 func (e errConfig) Error() string { return string(e) }
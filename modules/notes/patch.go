@@ -0,0 +1,286 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+var (
+	errUnsupportedPatch = errors.New("unsupported patch media type")
+	errPatchTestFailed  = errors.New("patch test failed")
+)
+
+// mergePatchReq описывает тело merge-patch для документации: все поля необязательны.
+type mergePatchReq struct {
+	Title   *string `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+}
+
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// notePatch — разобранный PATCH. Если ops пуст, достаточно title/content,
+// иначе операции применяются по порядку к текущему состоянию заметки.
+type notePatch struct {
+	title   *string
+	content *string
+	ops     []patchOp
+}
+
+// noteFields — изменяемая часть заметки, к которой применяется JSON Patch.
+type noteFields struct {
+	title   string
+	content string
+}
+
+func (f *noteFields) field(path string) (*string, error) {
+	switch path {
+	case "/title":
+		return &f.title, nil
+	case "/content":
+		return &f.content, nil
+	default:
+		return nil, queryError("invalid_patch_path")
+	}
+}
+
+func parsePatch(contentType string, body io.Reader) (notePatch, error) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mt != mergePatchType && mt != jsonPatchType) {
+		return notePatch{}, errUnsupportedPatch
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return notePatch{}, queryError("invalid_json")
+	}
+	if mt == mergePatchType {
+		return parseMergePatch(raw)
+	}
+	return parseJSONPatch(raw)
+}
+
+// parseMergePatch разбирает RFC 7396. null удаляет поле, а title и content обязательны,
+// поэтому null отклоняется так же, как пустое значение при создании.
+func parseMergePatch(raw []byte) (notePatch, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return notePatch{}, queryError("invalid_json")
+	}
+	if len(doc) == 0 {
+		return notePatch{}, queryError("empty_patch")
+	}
+
+	var p notePatch
+	for k, v := range doc {
+		var dst **string
+		switch k {
+		case "title":
+			dst = &p.title
+		case "content":
+			dst = &p.content
+		default:
+			return notePatch{}, queryError("unknown_field")
+		}
+		s, err := patchString(v)
+		if err != nil {
+			return notePatch{}, err
+		}
+		if k == "content" {
+			if err := checkContent(s); err != nil {
+				return notePatch{}, err
+			}
+		}
+		*dst = &s
+	}
+	return p, nil
+}
+
+// parseJSONPatch разбирает RFC 6902. Операции без обращения к текущему состоянию
+// (add, replace) сворачиваются в title/content; test и copy откладываются до транзакции.
+func parseJSONPatch(raw []byte) (notePatch, error) {
+	var ops []patchOp
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return notePatch{}, queryError("invalid_json")
+	}
+	if len(ops) == 0 {
+		return notePatch{}, queryError("empty_patch")
+	}
+
+	var p notePatch
+	var f noteFields
+	deferred := false
+	for _, op := range ops {
+		if _, err := f.field(op.Path); err != nil {
+			return notePatch{}, err
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			v, err := patchString(op.Value)
+			if err != nil {
+				return notePatch{}, err
+			}
+			if op.Path == "/content" {
+				if err := checkContent(v); err != nil {
+					return notePatch{}, err
+				}
+			}
+			deferred = deferred || op.Op == "test"
+		case "copy":
+			if _, err := f.field(op.From); err != nil {
+				return notePatch{}, err
+			}
+			deferred = true
+		case "remove", "move":
+			// результат остался бы без обязательного поля
+			return notePatch{}, queryError("title_and_content_required")
+		default:
+			return notePatch{}, queryError("invalid_patch_op")
+		}
+	}
+
+	if deferred {
+		p.ops = ops
+		return p, nil
+	}
+	for _, op := range ops {
+		s, _ := patchString(op.Value)
+		if op.Path == "/title" {
+			p.title = &s
+		} else {
+			p.content = &s
+		}
+	}
+	return p, nil
+}
+
+func patchString(v json.RawMessage) (string, error) {
+	if len(v) == 0 || string(v) == "null" {
+		return "", queryError("title_and_content_required")
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return "", queryError("invalid_field_type")
+	}
+	if s == "" {
+		return "", queryError("title_and_content_required")
+	}
+	return s, nil
+}
+
+// apply последовательно применяет отложенные операции JSON Patch к текущим значениям.
+func (p notePatch) apply(cur noteFields) (noteFields, error) {
+	for _, op := range p.ops {
+		dst, _ := cur.field(op.Path)
+		switch op.Op {
+		case "add", "replace":
+			*dst, _ = patchString(op.Value)
+		case "test":
+			if want, _ := patchString(op.Value); *dst != want {
+				return noteFields{}, errPatchTestFailed
+			}
+		case "copy":
+			src, _ := cur.field(op.From)
+			*dst = *src
+		}
+	}
+	return cur, nil
+}
+
+// patchNote меняет только переданные поля одним UPDATE. Отложенные операции
+// применяются к состоянию, прочитанному под блокировкой в той же транзакции.
+func patchNote(ctx context.Context, s caps.Setup, id int64, p notePatch, c change) (Note, bool, error) {
+	var n Note
+	var found bool
+
+	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		cur, exists, err := c.begin(ctx, tx, id, actionUpdate)
+		if err != nil || !exists {
+			return err
+		}
+
+		if len(p.ops) > 0 {
+			next, err := p.apply(noteFields{title: cur.Title, content: cur.Content})
+			if err != nil {
+				return err
+			}
+			p.title, p.content = &next.title, &next.content
+		}
+
+		row := tx.QueryRow(ctx, `
+			update notes
+			set title = coalesce($2, title),
+			    content = coalesce($3, content),
+			    updated_at = now()
+			where id = $1 and deleted_at is null
+			returning `+noteColumns("notes")+`
+		`, id, p.title, p.content)
+
+		if err := row.Scan(noteDest(&n)...); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		found = true
+		return nil
+	})
+
+	return n, found, err
+}
+
+func handlePatch(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		p, err := parsePatch(req.Header.Get("Content-Type"), req.Body)
+		if errors.Is(err, errUnsupportedPatch) {
+			w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+			writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		n, found, err := patchNote(req.Context(), s, id, p, changeOf(req))
+		switch {
+		case errors.Is(err, errPreconditionFailed):
+			writeError(w, http.StatusPreconditionFailed, "precondition_failed")
+			return
+		case errors.Is(err, errPatchTestFailed):
+			writeError(w, http.StatusConflict, "patch_test_failed")
+			return
+		case err != nil:
+			s.Log.ErrorContext(req.Context(), "patch note failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "update_failed")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		w.Header().Set("ETag", etagOf(n))
+		writeJSON(w, http.StatusOK, n)
+	}
+}
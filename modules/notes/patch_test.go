@@ -0,0 +1,68 @@
+package notes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	cases := []struct {
+		name, contentType, body, wantErr string
+	}{
+		{"merge title", mergePatchType, `{"title":"x"}`, ""},
+		{"merge with charset", mergePatchType + "; charset=utf-8", `{"content":"y"}`, ""},
+		{"merge null", mergePatchType, `{"title":null}`, "title_and_content_required"},
+		{"merge empty string", mergePatchType, `{"title":""}`, "title_and_content_required"},
+		{"merge unknown", mergePatchType, `{"id":1}`, "unknown_field"},
+		{"merge wrong type", mergePatchType, `{"title":1}`, "invalid_field_type"},
+		{"merge empty", mergePatchType, `{}`, "empty_patch"},
+		{"merge array", mergePatchType, `[]`, "invalid_json"},
+		{"json patch replace", jsonPatchType, `[{"op":"replace","path":"/title","value":"x"}]`, ""},
+		{"json patch remove", jsonPatchType, `[{"op":"remove","path":"/content"}]`, "title_and_content_required"},
+		{"json patch path", jsonPatchType, `[{"op":"replace","path":"/id","value":"x"}]`, "invalid_patch_path"},
+		{"json patch op", jsonPatchType, `[{"op":"frob","path":"/title","value":"x"}]`, "invalid_patch_op"},
+		{"merge content too long", mergePatchType, `{"content":"` + strings.Repeat("x", maxContentBytes+1) + `"}`, "content_too_long"},
+		{"json patch content too long", jsonPatchType, `[{"op":"add","path":"/content","value":"` + strings.Repeat("x", maxContentBytes+1) + `"}]`, "content_too_long"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parsePatch(c.contentType, strings.NewReader(c.body))
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.wantErr {
+				t.Fatalf("expected %s, got %v", c.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := parsePatch("application/json", strings.NewReader(`{}`)); !errors.Is(err, errUnsupportedPatch) {
+		t.Fatalf("expected unsupported media type, got %v", err)
+	}
+}
+
+func TestJSONPatchApply(t *testing.T) {
+	p, err := parsePatch(jsonPatchType, strings.NewReader(`[
+		{"op":"test","path":"/title","value":"old"},
+		{"op":"copy","from":"/title","path":"/content"},
+		{"op":"replace","path":"/title","value":"new"}
+	]`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got, err := p.apply(noteFields{title: "old", content: "c"})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got.title != "new" || got.content != "old" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	if _, err := p.apply(noteFields{title: "changed", content: "c"}); !errors.Is(err, errPatchTestFailed) {
+		t.Fatalf("expected test failure, got %v", err)
+	}
+}
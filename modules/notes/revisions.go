@@ -0,0 +1,324 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+// ActorHeader — необязательный заголовок с автором изменения, попадает в ревизию.
+const ActorHeader = "X-Actor"
+
+const maxAuthorLen = 200
+
+// Действия, после которых сохраняется ревизия (состояние заметки до изменения).
+const (
+	actionUpdate   = "update"
+	actionDelete   = "delete"
+	actionRollback = "rollback"
+)
+
+var errRevisionNotFound = errors.New("revision not found")
+
+type Revision struct {
+	NoteID    int64     `json:"noteId" db:"note_id"`
+	Rev       int       `json:"rev" db:"rev"`
+	Title     string    `json:"title" db:"title"`
+	Content   string    `json:"content" db:"content"`
+	Action    string    `json:"action" db:"action"`
+	Author    *string   `json:"author,omitempty" db:"author"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type revisionPage struct {
+	Items []Revision `json:"items"`
+	Page  pageInfo   `json:"page"`
+}
+
+// change — условия и автор изменения заметки из заголовков запроса.
+type change struct {
+	ifMatch string
+	author  string
+}
+
+func changeOf(req *http.Request) change {
+	author := strings.TrimSpace(req.Header.Get(ActorHeader))
+	for len(author) > maxAuthorLen {
+		_, size := utf8.DecodeLastRuneInString(author)
+		author = author[:len(author)-size]
+	}
+	return change{ifMatch: req.Header.Get("If-Match"), author: author}
+}
+
+// begin блокирует заметку, проверяет If-Match и сохраняет текущее состояние как ревизию
+// в той же транзакции, что и само изменение.
+func (c change) begin(ctx context.Context, tx pgx.Tx, id int64, action string) (Note, bool, error) {
+	n, found, err := lockNote(ctx, tx, id, c.ifMatch)
+	if err != nil || !found {
+		return Note{}, found, err
+	}
+
+	var author *string
+	if c.author != "" {
+		author = &c.author
+	}
+	// номер ревизии безопасно считать через max: строка заметки уже заблокирована
+	_, err = tx.Exec(ctx, `
+		insert into note_revisions (note_id, rev, title, content, action, author)
+		select $1, coalesce(max(rev), 0) + 1, $2::text, $3::text, $4::text, $5::text
+		from note_revisions
+		where note_id = $1
+	`, id, n.Title, n.Content, action, author)
+	if err != nil {
+		return Note{}, false, err
+	}
+	return n, true, nil
+}
+
+// noteExists учитывает и заметки в корзине: история удалённой заметки остаётся доступной.
+func noteExists(ctx context.Context, tx pgx.Tx, id int64) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(ctx, `select exists(select 1 from notes where id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func listRevisions(ctx context.Context, s caps.Setup, id int64, limit, offset int) ([]Revision, bool, error) {
+	var out []Revision
+	var found bool
+
+	err := s.Store.RunInReadTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if found, err = noteExists(ctx, tx, id); err != nil || !found {
+			return err
+		}
+		out, err = caps.Select[Revision](ctx, tx, `
+			select note_id, rev, title, content, action, author, created_at
+			from note_revisions
+			where note_id = $1
+			order by rev desc
+			limit $2 offset $3
+		`, id, limit+1, offset)
+		return err
+	})
+
+	return out, found, err
+}
+
+func getRevision(ctx context.Context, q caps.Querier, id int64, rev int) (Revision, error) {
+	r, found, err := caps.Get[Revision](ctx, q, `
+		select note_id, rev, title, content, action, author, created_at
+		from note_revisions
+		where note_id = $1 and rev = $2
+	`, id, rev)
+	if err == nil && !found {
+		return Revision{}, errRevisionNotFound
+	}
+	return r, err
+}
+
+// diffRevisions сравнивает ревизию from с ревизией to, а при to == 0 — с текущим состоянием заметки.
+func diffRevisions(ctx context.Context, s caps.Setup, id int64, from, to int) (string, error) {
+	var out string
+	err := s.Store.RunInReadTx(ctx, func(tx pgx.Tx) error {
+		a, err := getRevision(ctx, tx, id, from)
+		if err != nil {
+			return err
+		}
+
+		toLabel := "current"
+		var b noteFields
+		if to > 0 {
+			r, err := getRevision(ctx, tx, id, to)
+			if err != nil {
+				return err
+			}
+			toLabel = "rev " + strconv.Itoa(to)
+			b = noteFields{title: r.Title, content: r.Content}
+		} else {
+			err := tx.QueryRow(ctx, `select title, content from notes where id = $1`, id).Scan(&b.title, &b.content)
+			if err != nil {
+				return err
+			}
+		}
+
+		out = unifiedDiff("rev "+strconv.Itoa(from), toLabel, noteFields{title: a.Title, content: a.Content}, b)
+		return nil
+	})
+	return out, err
+}
+
+// rollbackNote возвращает заметку к ревизии rev; текущее состояние при этом тоже сохраняется ревизией.
+func rollbackNote(ctx context.Context, s caps.Setup, id int64, rev int, c change) (Note, bool, error) {
+	var n Note
+	var found bool
+
+	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		r, err := getRevision(ctx, tx, id, rev)
+		if err != nil {
+			return err
+		}
+		if _, exists, err := c.begin(ctx, tx, id, actionRollback); err != nil || !exists {
+			return err
+		}
+
+		row := tx.QueryRow(ctx, `
+			update notes
+			set title = $2,
+			    content = $3,
+			    updated_at = now()
+			where id = $1
+			returning `+noteColumns("notes")+`
+		`, id, r.Title, r.Content)
+		if err := row.Scan(noteDest(&n)...); err != nil {
+			return err
+		}
+		found = true
+		return nil
+	})
+
+	return n, found, err
+}
+
+func parseRev(w http.ResponseWriter, raw, code string) (int, bool) {
+	rev, err := strconv.Atoi(raw)
+	if err != nil || rev <= 0 {
+		writeError(w, http.StatusBadRequest, code)
+		return 0, false
+	}
+	return rev, true
+}
+
+func handleRevisions(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		limit, offset, _, err := parsePaging(req.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		revs, found, err := listRevisions(req.Context(), s, id, limit, offset)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "list note revisions failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "list_failed")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+
+		p := revisionPage{Items: revs, Page: pageInfo{Limit: limit, Offset: &offset, Sort: "-rev"}}
+		if len(revs) > limit {
+			p.Items = revs[:limit]
+			next := req.URL.Query()
+			next.Set("limit", strconv.Itoa(limit))
+			next.Set("offset", strconv.Itoa(offset+limit))
+			p.Page.Next = req.URL.Path + "?" + next.Encode()
+			w.Header().Set("Link", "<"+p.Page.Next+`>; rel="next"`)
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+func handleRevision(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		rev, ok := parseRev(w, chi.URLParam(req, "rev"), "invalid_rev")
+		if !ok {
+			return
+		}
+
+		r, err := getRevision(req.Context(), s.Store, id, rev)
+		if errors.Is(err, errRevisionNotFound) {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "get note revision failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "get_failed")
+			return
+		}
+		writeJSON(w, http.StatusOK, r)
+	}
+}
+
+func handleRevisionDiff(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		from, ok := parseRev(w, chi.URLParam(req, "rev"), "invalid_rev")
+		if !ok {
+			return
+		}
+		to := 0
+		if raw := req.URL.Query().Get("to"); raw != "" && raw != "current" {
+			if to, ok = parseRev(w, raw, "invalid_to"); !ok {
+				return
+			}
+		}
+
+		diff, err := diffRevisions(req.Context(), s, id, from, to)
+		if errors.Is(err, errRevisionNotFound) {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "diff note revisions failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "diff_failed")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(diff))
+	}
+}
+
+func handleRollback(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		rev, ok := parseRev(w, chi.URLParam(req, "rev"), "invalid_rev")
+		if !ok {
+			return
+		}
+
+		n, found, err := rollbackNote(req.Context(), s, id, rev, changeOf(req))
+		switch {
+		case errors.Is(err, errPreconditionFailed):
+			writeError(w, http.StatusPreconditionFailed, "precondition_failed")
+			return
+		case errors.Is(err, errRevisionNotFound):
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		case err != nil:
+			s.Log.ErrorContext(req.Context(), "rollback note failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "rollback_failed")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		w.Header().Set("ETag", etagOf(n))
+		writeJSON(w, http.StatusOK, n)
+	}
+}
@@ -0,0 +1,169 @@
+package notes
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+// searchConfig должен совпадать с конфигурацией в сгенерированной колонке notes.search.
+const searchConfig = "simple"
+
+const rankSort = "-rank"
+
+type searchHit struct {
+	Note
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchPage struct {
+	Items []searchHit `json:"items"`
+	Page  pageInfo    `json:"page"`
+}
+
+type searchQuery struct {
+	text       string
+	limit      int
+	offset     int
+	offsetMode bool
+	cursor     *cursor
+	rank       float32
+}
+
+func parseSearchQuery(v url.Values) (searchQuery, error) {
+	q := searchQuery{text: strings.TrimSpace(v.Get("q"))}
+	if q.text == "" {
+		return searchQuery{}, queryError("query_required")
+	}
+
+	var err error
+	q.limit, q.offset, q.offsetMode, err = parsePaging(v)
+	if err != nil {
+		return searchQuery{}, err
+	}
+
+	if c := v.Get("cursor"); c != "" {
+		if q.offsetMode {
+			return searchQuery{}, queryError("cursor_and_offset")
+		}
+		cur, err := decodeCursor(c)
+		if err != nil || cur.Sort != rankSort {
+			return searchQuery{}, queryError("invalid_cursor")
+		}
+		rank, err := strconv.ParseFloat(cur.Value, 32)
+		if err != nil {
+			return searchQuery{}, queryError("invalid_cursor")
+		}
+		q.cursor, q.rank = &cur, float32(rank)
+	}
+
+	return q, nil
+}
+
+// build ранжирует совпадения во вложенном запросе, а ts_headline считает только для строк страницы.
+func (q searchQuery) build() (string, []any) {
+	args := []any{q.text}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where := `n.search @@ q.query and n.deleted_at is null`
+	if q.cursor != nil {
+		where += ` and (ts_rank(n.search, q.query), n.id) < (` + arg(q.rank) + `::real, ` + arg(q.cursor.ID) + `)`
+	}
+	page := `limit ` + arg(q.limit+1)
+	if q.offsetMode {
+		page += ` offset ` + arg(q.offset)
+	}
+
+	sql := `
+		select id, title, content, created_at, updated_at, notebook_id, tags, rank,
+		       ts_headline('` + searchConfig + `', content, query,
+		                   'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+		from (
+			select ` + noteColumns("n") + `,
+			       ts_rank(n.search, q.query) as rank, q.query
+			from notes n, websearch_to_tsquery('` + searchConfig + `', $1) as q(query)
+			where ` + where + `
+			order by rank desc, n.id desc
+			` + page + `
+		) s
+		order by rank desc, id desc
+	`
+	return sql, args
+}
+
+func searchNotes(ctx context.Context, s caps.Setup, q searchQuery) ([]searchHit, error) {
+	sql, args := q.build()
+
+	// поиск терпит отставание реплики, а основную базу разгружает
+	rows, err := s.Store.Query(caps.WithReadReplica(ctx), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (searchHit, error) {
+		var h searchHit
+		err := row.Scan(append(noteDest(&h.Note), &h.Rank, &h.Snippet)...)
+		return h, err
+	})
+}
+
+func (q searchQuery) pageOf(req *http.Request, items []searchHit) searchPage {
+	p := searchPage{Items: items, Page: pageInfo{Limit: q.limit, Sort: rankSort}}
+	if q.offsetMode {
+		offset := q.offset
+		p.Page.Offset = &offset
+	}
+
+	if len(items) <= q.limit {
+		return p
+	}
+	p.Items = items[:q.limit]
+
+	next := req.URL.Query()
+	if q.offsetMode {
+		next.Set("offset", strconv.Itoa(q.offset+q.limit))
+	} else {
+		last := p.Items[len(p.Items)-1]
+		p.Page.NextCursor = encodeCursor(cursor{
+			Sort:  rankSort,
+			Value: strconv.FormatFloat(float64(last.Rank), 'g', -1, 32),
+			ID:    last.ID,
+		})
+		next.Set("cursor", p.Page.NextCursor)
+	}
+	next.Set("limit", strconv.Itoa(q.limit))
+	p.Page.Next = req.URL.Path + "?" + next.Encode()
+
+	return p
+}
+
+func handleSearch(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		q, err := parseSearchQuery(req.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		hits, err := searchNotes(req.Context(), s, q)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "search notes failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "search_failed")
+			return
+		}
+
+		p := q.pageOf(req, hits)
+		if p.Page.Next != "" {
+			w.Header().Set("Link", "<"+p.Page.Next+`>; rel="next"`)
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
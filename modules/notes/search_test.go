@@ -0,0 +1,49 @@
+package notes
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	if _, err := parseSearchQuery(url.Values{"q": {"  "}}); err == nil || err.Error() != "query_required" {
+		t.Fatalf("expected query_required, got %v", err)
+	}
+	if _, err := parseSearchQuery(url.Values{"q": {"x"}, "cursor": {encodeCursor(cursor{Sort: "-id", ID: 1})}}); err == nil || err.Error() != "invalid_cursor" {
+		t.Fatalf("expected invalid_cursor for list cursor, got %v", err)
+	}
+
+	q, err := parseSearchQuery(url.Values{"q": {"hello world"}, "limit": {"2"}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/notes/search?q=hello+world&limit=2", nil)
+	p := q.pageOf(req, []searchHit{
+		{Note: Note{ID: 9}, Rank: 0.6079271},
+		{Note: Note{ID: 4}, Rank: 0.0607927},
+		{Note: Note{ID: 3}, Rank: 0.01},
+	})
+	if len(p.Items) != 2 || p.Page.NextCursor == "" {
+		t.Fatalf("unexpected page: %+v", p.Page)
+	}
+
+	next, err := url.Parse(p.Page.Next)
+	if err != nil {
+		t.Fatalf("parse next: %v", err)
+	}
+	nq, err := parseSearchQuery(next.Query())
+	if err != nil {
+		t.Fatalf("parse next query: %v", err)
+	}
+	if nq.rank != 0.0607927 || nq.cursor.ID != 4 || nq.text != "hello world" {
+		t.Fatalf("cursor did not round-trip: rank=%v cursor=%+v", nq.rank, nq.cursor)
+	}
+
+	sql, args := nq.build()
+	if !strings.Contains(sql, "(ts_rank(n.search, q.query), n.id) < ($2::real, $3)") || len(args) != 4 {
+		t.Fatalf("unexpected keyset sql %q args %v", sql, args)
+	}
+}
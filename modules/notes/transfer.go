@@ -0,0 +1,531 @@
+package notes
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	csvType    = "text/csv"
+	ndjsonType = "application/x-ndjson"
+
+	exportBatch    = 500
+	maxImportBytes = 32 << 20
+	// tagSeparator разделяет теги в одной CSV-ячейке.
+	tagSeparator = ";"
+)
+
+// csvColumns — заголовок экспорта; при импорте id игнорируется.
+var csvColumns = []string{"id", "title", "content", "createdAt", "updatedAt", "notebookId", "tags"}
+
+// transferFormat выбирает формат по ?format=, затем по заголовку (Accept или Content-Type).
+func transferFormat(req *http.Request, header string) (string, error) {
+	switch f := req.URL.Query().Get("format"); f {
+	case formatCSV, formatNDJSON:
+		return f, nil
+	case "":
+	default:
+		return "", queryError("invalid_format")
+	}
+
+	for _, part := range strings.Split(req.Header.Get(header), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case csvType:
+			return formatCSV, nil
+		case ndjsonType, "application/ndjson":
+			return formatNDJSON, nil
+		}
+	}
+	return formatNDJSON, nil
+}
+
+// noteWriter пишет заметки в выбранном формате.
+type noteWriter interface {
+	write(n Note) error
+	flush() error
+}
+
+type csvNoteWriter struct{ w *csv.Writer }
+
+func (c csvNoteWriter) write(n Note) error {
+	var notebook string
+	if n.NotebookID != nil {
+		notebook = strconv.FormatInt(*n.NotebookID, 10)
+	}
+	return c.w.Write([]string{
+		strconv.FormatInt(n.ID, 10),
+		n.Title,
+		n.Content,
+		n.CreatedAt.Format(time.RFC3339Nano),
+		n.UpdatedAt.Format(time.RFC3339Nano),
+		notebook,
+		strings.Join(n.Tags, tagSeparator),
+	})
+}
+
+func (c csvNoteWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonNoteWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (j ndjsonNoteWriter) write(n Note) error { return j.enc.Encode(n) }
+func (j ndjsonNoteWriter) flush() error       { return j.w.Flush() }
+
+// exportNotes читает заметки серверным курсором порциями по exportBatch, поэтому
+// объём выгрузки не ограничен памятью. start вызывается один раз перед первой записью.
+func exportNotes(ctx context.Context, s caps.Setup, start func() noteWriter, flush func()) error {
+	return s.Store.RunInReadTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			declare notes_export no scroll cursor for
+			select `+noteColumns("notes")+`
+			from notes
+			where deleted_at is null
+			order by id
+		`)
+		if err != nil {
+			return err
+		}
+
+		nw := start()
+		for {
+			rows, err := tx.Query(ctx, `fetch forward `+strconv.Itoa(exportBatch)+` from notes_export`)
+			if err != nil {
+				return err
+			}
+			count := 0
+			for rows.Next() {
+				var n Note
+				if err := rows.Scan(noteDest(&n)...); err != nil {
+					rows.Close()
+					return err
+				}
+				if err := nw.write(n); err != nil {
+					rows.Close()
+					return err
+				}
+				count++
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if err := nw.flush(); err != nil {
+				return err
+			}
+			flush()
+			if count < exportBatch {
+				return nil
+			}
+		}
+	})
+}
+
+func handleExport(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		format, err := transferFormat(req, "Accept")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		started := false
+		rc := http.NewResponseController(w)
+		err = exportNotes(req.Context(), s, func() noteWriter {
+			started = true
+			if format == formatCSV {
+				w.Header().Set("Content-Type", csvType+"; charset=utf-8")
+				w.Header().Set("Content-Disposition", `attachment; filename="notes.csv"`)
+				cw := csv.NewWriter(w)
+				_ = cw.Write(csvColumns)
+				return csvNoteWriter{w: cw}
+			}
+			w.Header().Set("Content-Type", ndjsonType)
+			w.Header().Set("Content-Disposition", `attachment; filename="notes.ndjson"`)
+			bw := bufio.NewWriter(w)
+			return ndjsonNoteWriter{w: bw, enc: json.NewEncoder(bw)}
+		}, func() { _ = rc.Flush() })
+
+		if err == nil {
+			return
+		}
+		s.Log.ErrorContext(req.Context(), "export notes failed", "error", err)
+		if !started {
+			writeError(w, http.StatusInternalServerError, "export_failed")
+			return
+		}
+		// статус уже отправлен: обрываем ответ, чтобы клиент не принял неполную выгрузку за целую
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// importRow — строка импорта после разбора, line — номер строки во входных данных (с 1).
+type importRow struct {
+	line       int
+	title      string
+	content    string
+	notebookID *int64
+	tags       []string
+	createdAt  time.Time
+	updatedAt  time.Time
+}
+
+type importReject struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type importSummary struct {
+	Inserted int            `json:"inserted"`
+	Rejected int            `json:"rejected"`
+	IDs      []int64        `json:"ids"`
+	Errors   []importReject `json:"errors"`
+}
+
+// ndjsonRow — поля Note, которые учитываются при импорте; остальные игнорируются.
+type ndjsonRow struct {
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	NotebookID *int64     `json:"notebookId"`
+	Tags       []string   `json:"tags"`
+	CreatedAt  *time.Time `json:"createdAt"`
+	UpdatedAt  *time.Time `json:"updatedAt"`
+}
+
+// validate проверяет строку так же, как POST /notes, и подставляет недостающие даты.
+func (r *importRow) validate(now time.Time) error {
+	if r.title == "" || r.content == "" {
+		return queryError("title_and_content_required")
+	}
+	if err := checkContent(r.content); err != nil {
+		return err
+	}
+	tags, err := normalizeTags(r.tags)
+	if err != nil {
+		return err
+	}
+	r.tags = tags
+	if r.createdAt.IsZero() {
+		r.createdAt = now
+	}
+	if r.updatedAt.IsZero() {
+		r.updatedAt = r.createdAt
+	}
+	return nil
+}
+
+func parseNDJSON(body io.Reader, now time.Time) ([]importRow, []importReject, error) {
+	var rows []importRow
+	var rejects []importReject
+
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 64*1024), maxImportBytes)
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := strings.TrimSpace(sc.Text())
+		if raw == "" {
+			continue
+		}
+		var in ndjsonRow
+		if err := json.Unmarshal([]byte(raw), &in); err != nil {
+			rejects = append(rejects, importReject{Line: line, Error: "invalid_json"})
+			continue
+		}
+		r := importRow{line: line, title: in.Title, content: in.Content, notebookID: in.NotebookID, tags: in.Tags}
+		if in.CreatedAt != nil {
+			r.createdAt = *in.CreatedAt
+		}
+		if in.UpdatedAt != nil {
+			r.updatedAt = *in.UpdatedAt
+		}
+		if err := r.validate(now); err != nil {
+			rejects = append(rejects, importReject{Line: line, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, r)
+	}
+	return rows, rejects, sc.Err()
+}
+
+// parseCSV требует строку заголовка; колонки сопоставляются по именам из csvColumns.
+func parseCSV(body io.Reader, now time.Time) ([]importRow, []importReject, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, queryError("invalid_csv")
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if !isCSVColumn(name) {
+			return nil, nil, queryError("unknown_column")
+		}
+		col[name] = i
+	}
+	if _, ok := col["title"]; !ok {
+		return nil, nil, queryError("missing_column")
+	}
+	if _, ok := col["content"]; !ok {
+		return nil, nil, queryError("missing_column")
+	}
+
+	var rows []importRow
+	var rejects []importReject
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				rejects = append(rejects, importReject{Line: perr.StartLine, Error: "invalid_csv"})
+				continue
+			}
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) != len(header) {
+			rejects = append(rejects, importReject{Line: line, Error: "invalid_csv"})
+			continue
+		}
+
+		r, err := csvRow(rec, col, line)
+		if err == nil {
+			err = r.validate(now)
+		}
+		if err != nil {
+			rejects = append(rejects, importReject{Line: line, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, r)
+	}
+	return rows, rejects, nil
+}
+
+func isCSVColumn(name string) bool {
+	for _, c := range csvColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func csvRow(rec []string, col map[string]int, line int) (importRow, error) {
+	get := func(name string) string {
+		if i, ok := col[name]; ok {
+			return rec[i]
+		}
+		return ""
+	}
+
+	r := importRow{line: line, title: get("title"), content: get("content")}
+	if v := strings.TrimSpace(get("notebookId")); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return importRow{}, queryError("invalid_notebook")
+		}
+		r.notebookID = &id
+	}
+	if v := get("tags"); strings.TrimSpace(v) != "" {
+		r.tags = strings.Split(v, tagSeparator)
+	}
+	var err error
+	if v := get("createdAt"); v != "" {
+		if r.createdAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return importRow{}, queryError("invalid_created_at")
+		}
+	}
+	if v := get("updatedAt"); v != "" {
+		if r.updatedAt, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return importRow{}, queryError("invalid_updated_at")
+		}
+	}
+	return r, nil
+}
+
+// importNotes загружает строки через COPY одной транзакцией. Результат возвращается,
+// только если транзакция зафиксирована.
+func importNotes(ctx context.Context, s caps.Setup, rows []importRow) ([]int64, []importReject, error) {
+	var ids []int64
+	var rejects []importReject
+
+	err := s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		// каждая попытка (RunInTx повторяет транзакцию после ошибки сериализации)
+		// начинает с исходных строк, иначе отклонённые в прошлый раз пропали бы из отчёта
+		var err error
+		ids, rejects, err = importBatch(ctx, tx, rows)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ids, rejects, nil
+}
+
+// importBatch выделяет id из последовательности заранее, чтобы затем привязать теги;
+// строки с несуществующим блокнотом отклоняются до COPY.
+func importBatch(ctx context.Context, tx pgx.Tx, rows []importRow) ([]int64, []importReject, error) {
+	var ids []int64
+	var rejects []importReject
+
+	var notebookIDs []int64
+	for _, r := range rows {
+		if r.notebookID != nil {
+			notebookIDs = append(notebookIDs, *r.notebookID)
+		}
+	}
+	if len(notebookIDs) > 0 {
+		known := make(map[int64]bool)
+		existing, err := tx.Query(ctx, `select id from notebooks where id = any($1)`, notebookIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		nbIDs, err := pgx.CollectRows(existing, pgx.RowTo[int64])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range nbIDs {
+			known[id] = true
+		}
+		valid := make([]importRow, 0, len(rows))
+		for _, r := range rows {
+			if r.notebookID != nil && !known[*r.notebookID] {
+				rejects = append(rejects, importReject{Line: r.line, Error: "invalid_notebook"})
+				continue
+			}
+			valid = append(valid, r)
+		}
+		rows = valid
+	}
+	if len(rows) == 0 {
+		return nil, rejects, nil
+	}
+
+	seq, err := tx.Query(ctx, `select nextval(pg_get_serial_sequence('notes', 'id')) from generate_series(1, $1)`, len(rows))
+	if err != nil {
+		return nil, nil, err
+	}
+	if ids, err = pgx.CollectRows(seq, pgx.RowTo[int64]); err != nil {
+		return nil, nil, err
+	}
+
+	var tagNotes []int64
+	var tagNames []string
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"notes"},
+		[]string{"id", "title", "content", "notebook_id", "created_at", "updated_at"},
+		pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+			r := rows[i]
+			for _, t := range r.tags {
+				tagNotes = append(tagNotes, ids[i])
+				tagNames = append(tagNames, t)
+			}
+			return []any{ids[i], r.title, r.content, r.notebookID, r.createdAt, r.updatedAt}, nil
+		}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tagNames) == 0 {
+		return ids, rejects, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		insert into tags (name)
+		select distinct unnest($1::text[])
+		on conflict (name) do nothing
+	`, tagNames); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		insert into note_tags (note_id, tag_id)
+		select x.note_id, t.id
+		from unnest($1::bigint[], $2::text[]) as x(note_id, name)
+		join tags t on t.name = x.name
+	`, tagNotes, tagNames); err != nil {
+		return nil, nil, err
+	}
+	return ids, rejects, nil
+}
+
+func handleImport(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		format, err := transferFormat(req, "Content-Type")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		body := http.MaxBytesReader(w, req.Body, maxImportBytes)
+		now := time.Now().UTC()
+		var rows []importRow
+		var rejects []importReject
+		if format == formatCSV {
+			rows, rejects, err = parseCSV(body, now)
+		} else {
+			rows, rejects, err = parseNDJSON(body, now)
+		}
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr), errors.Is(err, bufio.ErrTooLong):
+			writeError(w, http.StatusRequestEntityTooLarge, "too_large")
+			return
+		case err != nil:
+			code := "invalid_body"
+			var qe queryError
+			if errors.As(err, &qe) {
+				code = qe.Error()
+			}
+			writeError(w, http.StatusBadRequest, code)
+			return
+		}
+
+		ids, missing, err := importNotes(req.Context(), s, rows)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "import notes failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "import_failed")
+			return
+		}
+		rejects = append(rejects, missing...)
+		sort.Slice(rejects, func(i, j int) bool { return rejects[i].Line < rejects[j].Line })
+
+		sum := importSummary{Inserted: len(ids), Rejected: len(rejects), IDs: ids, Errors: rejects}
+		if sum.IDs == nil {
+			sum.IDs = []int64{}
+		}
+		if sum.Errors == nil {
+			sum.Errors = []importReject{}
+		}
+		writeJSON(w, http.StatusOK, sum)
+	}
+}
@@ -0,0 +1,100 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransferFormat(t *testing.T) {
+	cases := []struct {
+		url, accept, want string
+	}{
+		{"/notes/export", "", formatNDJSON},
+		{"/notes/export", "text/csv; charset=utf-8", formatCSV},
+		{"/notes/export", "application/json, application/x-ndjson", formatNDJSON},
+		{"/notes/export?format=csv", "application/x-ndjson", formatCSV},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.url, nil)
+		req.Header.Set("Accept", c.accept)
+		if got, err := transferFormat(req, "Accept"); err != nil || got != c.want {
+			t.Fatalf("%s %q: got %q, %v; want %q", c.url, c.accept, got, err, c.want)
+		}
+	}
+
+	if _, err := transferFormat(httptest.NewRequest("GET", "/notes/export?format=xml", nil), "Accept"); err == nil {
+		t.Fatal("expected invalid_format")
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	nb := int64(3)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	notes := []Note{
+		{ID: 1, Title: "a, b", Content: "multi\nline \"quoted\"", CreatedAt: created, UpdatedAt: created, NotebookID: &nb, Tags: []string{"go", "work"}},
+		{ID: 2, Title: "plain", Content: "c", CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	_ = cw.Write(csvColumns)
+	w := csvNoteWriter{w: cw}
+	for _, n := range notes {
+		if err := w.write(n); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	buf.WriteString("3,,empty title,,,,\n")
+
+	rows, rejects, err := parseCSV(&buf, time.Now())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rows) != 2 || len(rejects) != 1 || rejects[0].Error != "title_and_content_required" || rejects[0].Line != 5 {
+		t.Fatalf("unexpected parse result: rows=%+v rejects=%+v", rows, rejects)
+	}
+	r := rows[0]
+	if r.title != "a, b" || r.content != notes[0].Content || r.notebookID == nil || *r.notebookID != 3 ||
+		strings.Join(r.tags, "|") != "go|work" || !r.createdAt.Equal(created) || r.line != 2 {
+		t.Fatalf("unexpected first row: %+v", r)
+	}
+	if !rows[1].updatedAt.Equal(created.Add(time.Hour)) {
+		t.Fatalf("unexpected second row: %+v", rows[1])
+	}
+}
+
+func TestParseCSVHeader(t *testing.T) {
+	if _, _, err := parseCSV(strings.NewReader("title,color\n"), time.Now()); err == nil || err.Error() != "unknown_column" {
+		t.Fatalf("expected unknown_column, got %v", err)
+	}
+	if _, _, err := parseCSV(strings.NewReader("title\n"), time.Now()); err == nil || err.Error() != "missing_column" {
+		t.Fatalf("expected missing_column, got %v", err)
+	}
+}
+
+func TestParseNDJSON(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	body := `{"id": 7, "title": "t", "content": "c", "tags": ["x", "x"]}
+
+not json
+{"title": "", "content": "c"}
+{"title": "t", "content": "c", "createdAt": "2023-01-01T00:00:00Z"}
+`
+	rows, rejects, err := parseNDJSON(strings.NewReader(body), now)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rows) != 2 || len(rejects) != 2 || rejects[0].Line != 3 || rejects[0].Error != "invalid_json" || rejects[1].Line != 4 {
+		t.Fatalf("unexpected result: rows=%+v rejects=%+v", rows, rejects)
+	}
+	if len(rows[0].tags) != 1 || !rows[0].createdAt.Equal(now) || rows[1].updatedAt.Year() != 2023 {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+}
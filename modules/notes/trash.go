@@ -0,0 +1,152 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+)
+
+func listTrash(ctx context.Context, s caps.Setup, limit, offset int) ([]Note, error) {
+	rows, err := s.Store.Query(ctx, `
+		select `+noteColumns("notes")+`, deleted_at
+		from notes
+		where deleted_at is not null
+		order by deleted_at desc, id desc
+		limit $1 offset $2
+	`, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Note, error) {
+		var n Note
+		err := row.Scan(append(noteDest(&n), &n.DeletedAt)...)
+		return n, err
+	})
+}
+
+func restoreNote(ctx context.Context, s caps.Setup, id int64) (Note, bool, error) {
+	var n Note
+	err := s.Store.QueryRow(ctx, `
+		update notes
+		set deleted_at = null
+		where id = $1 and deleted_at is not null
+		returning `+noteColumns("notes")+`
+	`, id).Scan(noteDest(&n)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Note{}, false, nil
+	}
+	return n, err == nil, err
+}
+
+func purgeNote(ctx context.Context, s caps.Setup, id int64) (bool, error) {
+	rows, err := s.Store.ExecResult(ctx, `delete from notes where id = $1`, id)
+	return rows > 0, err
+}
+
+// purgeTrash удаляет заметки, пролежавшие в корзине дольше retention.
+func purgeTrash(ctx context.Context, s caps.Setup, retention time.Duration) (int64, error) {
+	return s.Store.ExecResult(ctx, `
+		delete from notes
+		where deleted_at is not null and deleted_at < now() - $1::interval
+	`, retention)
+}
+
+// Run периодически очищает корзину, пока не отменён ctx. Без retention ничего не делает.
+func (m *Module) Run(ctx context.Context) {
+	if m.opts.trashRetention <= 0 || m.opts.purgeInterval <= 0 || m.s.Store == nil {
+		return
+	}
+
+	t := time.NewTicker(m.opts.purgeInterval)
+	defer t.Stop()
+
+	for {
+		n, err := purgeTrash(ctx, m.s, m.opts.trashRetention)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			m.s.Log.ErrorContext(ctx, "purge notes trash failed", "error", err)
+		case n > 0:
+			m.s.Log.InfoContext(ctx, "purged notes trash", "count", n, "retention", m.opts.trashRetention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func handleTrash(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		limit, offset, _, err := parsePaging(req.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		notes, err := listTrash(req.Context(), s, limit, offset)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "list notes trash failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "list_failed")
+			return
+		}
+
+		p := listPage{Items: notes, Page: pageInfo{Limit: limit, Offset: &offset, Sort: "-deletedAt"}}
+		if len(notes) > limit {
+			p.Items = notes[:limit]
+			next := req.URL.Query()
+			next.Set("limit", strconv.Itoa(limit))
+			next.Set("offset", strconv.Itoa(offset+limit))
+			p.Page.Next = req.URL.Path + "?" + next.Encode()
+			w.Header().Set("Link", "<"+p.Page.Next+`>; rel="next"`)
+		}
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+func handleRestore(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		n, found, err := restoreNote(req.Context(), s, id)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "restore note failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "restore_failed")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		w.Header().Set("ETag", etagOf(n))
+		writeJSON(w, http.StatusOK, n)
+	}
+}
+
+func handlePurge(s caps.Setup) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id, ok := parseID(w, req)
+		if !ok {
+			return
+		}
+		found, err := purgeNote(req.Context(), s, id)
+		if err != nil {
+			s.Log.ErrorContext(req.Context(), "purge note failed", "error", err)
+			writeError(w, http.StatusInternalServerError, "purge_failed")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "not_found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
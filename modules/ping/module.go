@@ -29,7 +29,7 @@ func (m *Module) Register(s caps.Setup) error {
 		r.Get("/", func(w http.ResponseWriter, req *http.Request) {
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
 			_, _ = w.Write([]byte(`{"message":"pong","ts":"` + time.Now().UTC().Format(time.RFC3339Nano) + `"}`))
-		})
+		}, caps.Summary("Ping"), caps.Returns(http.StatusOK, meta.EntityRef("Ping")))
 	})
 
 	return nil
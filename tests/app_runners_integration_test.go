@@ -0,0 +1,81 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/app"
+	"github.com/Illusiard/miniapi/internal/config"
+)
+
+// TestAppRunsTrashPurge проверяет, что фоновая очистка корзины работает, пока App обслуживает запросы.
+func TestAppRunsTrashPurge(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	a := app.New(config.Config{
+		HTTPAddr:             "127.0.0.1:0",
+		DatabaseURL:          dbURL,
+		MigrationsCheck:      config.MigrationsCheckOff,
+		SchemaDriftCheck:     config.DriftCheckOff,
+		ReplicaCheckInterval: time.Second,
+		NotesTrashRetention:  time.Hour,
+		NotesPurgeInterval:   100 * time.Millisecond,
+	})
+
+	runCtx, stop := context.WithCancel(ctx)
+	started := make(chan error, 1)
+	go func() { started <- a.Start(runCtx) }()
+	t.Cleanup(func() {
+		stop()
+		if err := <-started; err != nil {
+			t.Errorf("start: %v", err)
+		}
+		stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = a.Stop(stopCtx)
+	})
+
+	var id int64
+	if err := pool.QueryRow(ctx, `
+insert into notes (title, content, deleted_at) values ('old', 'c', now() - interval '2 hours')
+returning id`).Scan(&id); err != nil {
+		t.Fatalf("insert trashed note: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var exists bool
+		if err := pool.QueryRow(ctx, `select exists(select 1 from notes where id = $1)`, id).Scan(&exists); err != nil {
+			t.Fatalf("select note: %v", err)
+		}
+		if !exists {
+			return
+		}
+		select {
+		case err := <-started:
+			started <- err // для Cleanup
+			t.Fatalf("app stopped before purging the trash: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("trashed note was not purged while the server was running")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,174 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/migrations"
+	"github.com/Illusiard/miniapi/modules/notes"
+)
+
+func TestModuleMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	version := func(table string) int64 {
+		t.Helper()
+		var v int64
+		var dirty bool
+		if err := pool.QueryRow(ctx, `select version, dirty from `+table).Scan(&v, &dirty); err != nil {
+			t.Fatalf("read %s: %v", table, err)
+		}
+		if dirty {
+			t.Fatalf("%s is dirty at %d", table, v)
+		}
+		return v
+	}
+	exists := func(table string) bool {
+		t.Helper()
+		var ok bool
+		if err := pool.QueryRow(ctx, `select to_regclass($1) is not null`, table).Scan(&ok); err != nil {
+			t.Fatalf("to_regclass: %v", err)
+		}
+		return ok
+	}
+
+	const latest = 5
+	table := migrations.Table("notes")
+	if got := version(table); got != latest {
+		t.Fatalf("notes version = %d, want %d", got, latest)
+	}
+	if exists("schema_migrations") {
+		t.Fatal("fresh database must not have the shared version table")
+	}
+
+	runner := migrations.New([]migrations.Set{notesSet(dbURL)})
+	if err := runner.Up(ctx); err != nil {
+		t.Fatalf("repeated up: %v", err)
+	}
+
+	// база, размеченная до модульных миграций: версия только в общей schema_migrations
+	if _, err := pool.Exec(ctx, `alter table `+table+` rename to schema_migrations`); err != nil {
+		t.Fatalf("simulate legacy: %v", err)
+	}
+	if err := runner.Up(ctx); err != nil {
+		t.Fatalf("up over legacy table: %v", err)
+	}
+	if got := version(table); got != latest {
+		t.Fatalf("adopted version = %d, want %d", got, latest)
+	}
+	if exists("schema_migrations") || !exists("schema_migrations_legacy") {
+		t.Fatal("legacy table must be renamed to schema_migrations_legacy")
+	}
+}
+
+func TestMigrationCommands(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	runner := migrations.New([]migrations.Set{notesSet(dbURL)})
+
+	status := func() migrations.Status {
+		t.Helper()
+		st, err := runner.Status(ctx)
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		if len(st) != 1 {
+			t.Fatalf("status: %+v", st)
+		}
+		return st[0]
+	}
+	applied := func(st migrations.Status) int {
+		n := 0
+		for _, m := range st.Migrations {
+			if m.State == migrations.StateApplied {
+				n++
+			}
+		}
+		return n
+	}
+
+	st := status()
+	if st.Version != 5 || st.Latest != 5 || st.Dirty || len(st.Migrations) != 5 || applied(st) != 5 || st.Err() != nil {
+		t.Fatalf("initial status: %+v", st)
+	}
+	if st.Migrations[0].Name != "create_notes" {
+		t.Fatalf("migration name: %q", st.Migrations[0].Name)
+	}
+
+	if err := runner.Down(ctx, "notes", 2); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if st = status(); st.Version != 3 || applied(st) != 3 || st.Err() == nil {
+		t.Fatalf("after down: %+v", st)
+	}
+
+	if err := runner.Goto(ctx, "notes", 5); err != nil {
+		t.Fatalf("goto: %v", err)
+	}
+	if st = status(); st.Version != 5 {
+		t.Fatalf("after goto: %+v", st)
+	}
+
+	if err := runner.Force(ctx, "notes", 4); err != nil {
+		t.Fatalf("force: %v", err)
+	}
+	if st = status(); st.Version != 4 || st.Dirty {
+		t.Fatalf("after force: %+v", st)
+	}
+
+	if err := runner.Check(ctx); err == nil || !strings.Contains(err.Error(), "version 4, latest 5") {
+		t.Fatalf("check after force: %v", err)
+	}
+
+	// упавшая миграция: версия помечена dirty
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	if _, err := pool.Exec(ctx, `update `+migrations.Table("notes")+` set dirty = true`); err != nil {
+		t.Fatalf("mark dirty: %v", err)
+	}
+	if st = status(); !st.Dirty || st.Migrations[3].State != migrations.StateDirty || st.Migrations[4].State != migrations.StatePending {
+		t.Fatalf("dirty status: %+v", st)
+	}
+	if err := runner.Check(ctx); err == nil || !strings.Contains(err.Error(), "dirty at version 4") {
+		t.Fatalf("check dirty: %v", err)
+	}
+
+	if err := runner.Force(ctx, "notes", 5); err != nil {
+		t.Fatalf("force latest: %v", err)
+	}
+	if err := runner.Check(ctx); err != nil {
+		t.Fatalf("check latest: %v", err)
+	}
+
+	if err := runner.Down(ctx, "billing", 1); err == nil {
+		t.Fatal("unknown module must fail")
+	}
+}
+
+func notesSet(dbURL string) migrations.Set {
+	return migrations.Set{Module: "notes", FS: notes.New().Migrations(), DatabaseURL: dbURL}
+}
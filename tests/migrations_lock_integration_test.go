@@ -0,0 +1,251 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/migrations"
+)
+
+// migrationLockKey повторяет ключ из internal/migrations («miniapi!» в ASCII).
+const migrationLockKey int64 = 0x6d696e6961706921
+
+func sqlFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, body := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(body)}
+	}
+	return fsys
+}
+
+func TestMigrationsConcurrentUp(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	sets := []migrations.Set{notesSet(dbURL)}
+	if err := migrations.New(sets).Down(ctx, "notes", 5); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+
+	// несколько экземпляров стартуют с AUTO_MIGRATE=1 одновременно
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- migrations.New(sets).Up(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent up: %v", err)
+		}
+	}
+	if err := migrations.New(sets).Check(ctx); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+}
+
+func TestMigrationsLockTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	// «другой экземпляр» держит блокировку
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `select pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+
+	start := time.Now()
+	err = migrations.New([]migrations.Set{notesSet(dbURL)}, migrations.WithLockTimeout(300*time.Millisecond)).Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "another instance is still migrating") {
+		t.Fatalf("expected lock timeout, got %v", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("lock wait took %s", d)
+	}
+
+	if _, err := conn.Exec(ctx, `select pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := migrations.New([]migrations.Set{notesSet(dbURL)}, migrations.WithLockTimeout(300*time.Millisecond)).Up(ctx); err != nil {
+		t.Fatalf("up after unlock: %v", err)
+	}
+}
+
+func TestMigrationsLockSameDatabase(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	// та же база под другим URL (другая запись хоста и параметры):
+	// второй advisory lock ждал бы первый до lockTimeout
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	if u.Hostname() == "localhost" {
+		u.Host = net.JoinHostPort("127.0.0.1", u.Port())
+	} else {
+		u.Host = net.JoinHostPort("localhost", u.Port())
+	}
+	q := u.Query()
+	q.Set("application_name", "extra")
+	u.RawQuery = q.Encode()
+	extra := migrations.Set{Module: "extra", DatabaseURL: u.String(), FS: sqlFS(map[string]string{
+		"000001_init.up.sql":   `create table extra_items (id int);`,
+		"000001_init.down.sql": `drop table extra_items;`,
+	})}
+
+	start := time.Now()
+	r := migrations.New([]migrations.Set{notesSet(dbURL), extra}, migrations.WithLockTimeout(2*time.Second))
+	if err := r.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if d := time.Since(start); d >= 2*time.Second {
+		t.Fatalf("up took %s, lock waited on itself", d)
+	}
+	if err := r.Check(ctx); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+}
+
+func TestMigrationsFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	status := func(r *migrations.Runner) migrations.Status {
+		t.Helper()
+		st, err := r.Status(ctx)
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		return st[0]
+	}
+	tableExists := func(name string) bool {
+		t.Helper()
+		var ok bool
+		if err := pool.QueryRow(ctx, `select to_regclass($1) is not null`, name).Scan(&ok); err != nil {
+			t.Fatalf("to_regclass: %v", err)
+		}
+		return ok
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		set := migrations.Set{Module: "broken", DatabaseURL: dbURL, FS: sqlFS(map[string]string{
+			"000001_first.up.sql":    `create table broken_first (id int);`,
+			"000001_first.down.sql":  `drop table if exists broken_first;`,
+			"000002_second.up.sql":   `create table broken_second (id int); select 1/0;`,
+			"000002_second.down.sql": `drop table if exists broken_second;`,
+		})}
+
+		r := migrations.New([]migrations.Set{set}, migrations.WithRollback(true))
+		err := r.Up(ctx)
+		if err == nil || !strings.Contains(err.Error(), "rolled back") {
+			t.Fatalf("expected rolled back failure, got %v", err)
+		}
+		if st := status(r); st.Version != 1 || st.Dirty {
+			t.Fatalf("after rollback: %+v", st)
+		}
+		if !tableExists("broken_first") || tableExists("broken_second") {
+			t.Fatal("rollback must keep version 1 and drop the failed step")
+		}
+
+		// без отката схема остаётся dirty
+		r = migrations.New([]migrations.Set{set})
+		if err := r.Up(ctx); err == nil || !strings.Contains(err.Error(), "dirty") {
+			t.Fatalf("expected dirty failure, got %v", err)
+		}
+		if st := status(r); st.Version != 2 || !st.Dirty {
+			t.Fatalf("without rollback: %+v", st)
+		}
+	})
+
+	t.Run("no down file", func(t *testing.T) {
+		set := migrations.Set{Module: "nodown", DatabaseURL: dbURL, FS: sqlFS(map[string]string{
+			"000001_fail.up.sql": `select 1/0;`,
+		})}
+		r := migrations.New([]migrations.Set{set}, migrations.WithRollback(true))
+		if err := r.Up(ctx); err == nil || !strings.Contains(err.Error(), "no down migration") {
+			t.Fatalf("expected missing down error, got %v", err)
+		}
+		if st := status(r); !st.Dirty {
+			t.Fatalf("must stay dirty: %+v", st)
+		}
+	})
+
+	t.Run("statement timeout", func(t *testing.T) {
+		set := migrations.Set{Module: "slow", DatabaseURL: dbURL, FS: sqlFS(map[string]string{
+			"000001_slow.up.sql":   `select pg_sleep(30);`,
+			"000001_slow.down.sql": `select 1;`,
+		})}
+		start := time.Now()
+		r := migrations.New([]migrations.Set{set}, migrations.WithStatementTimeout(300*time.Millisecond), migrations.WithRollback(true))
+		if err := r.Up(ctx); err == nil {
+			t.Fatal("expected statement timeout")
+		}
+		if d := time.Since(start); d > 10*time.Second {
+			t.Fatalf("statement ran for %s", d)
+		}
+		if st := status(r); st.Version != 0 || st.Dirty {
+			t.Fatalf("after rollback: %+v", st)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		set := migrations.Set{Module: "canceled", DatabaseURL: dbURL, FS: sqlFS(map[string]string{
+			"000001_slow.up.sql": `select pg_sleep(30);`,
+		})}
+		cctx, ccancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer ccancel()
+
+		start := time.Now()
+		if err := migrations.New([]migrations.Set{set}).Up(cctx); err == nil {
+			t.Fatal("expected cancellation error")
+		}
+		if d := time.Since(start); d > 10*time.Second {
+			t.Fatalf("canceled migration ran for %s", d)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type bulkResultDTO struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	Status int              `json:"status"`
+	ID     int64            `json:"id"`
+	Error  string           `json:"error"`
+	Note   *json.RawMessage `json:"note"`
+}
+
+type bulkResponseDTO struct {
+	Mode      string          `json:"mode"`
+	Committed bool            `json:"committed"`
+	Results   []bulkResultDTO `json:"results"`
+}
+
+func TestNotesBulk(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	existing := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "existing", Content: "c"}, http.StatusCreated)
+
+	// atomic: ошибка во второй операции откатывает первую
+	resp := mustDoJSON[bulkResponseDTO](t, http.MethodPost, srv.URL+"/notes/bulk", []map[string]any{
+		{"op": "create", "title": "rolled back", "content": "c"},
+		{"op": "delete", "id": existing.ID + 1000},
+		{"op": "delete", "id": existing.ID},
+	}, http.StatusUnprocessableEntity)
+	if resp.Committed || len(resp.Results) != 2 || resp.Results[1].Status != http.StatusNotFound || resp.Results[1].Error != "not_found" {
+		t.Fatalf("unexpected atomic response: %+v", resp)
+	}
+	list := mustDoJSON[[]noteDTO](t, http.MethodGet, srv.URL+"/notes", nil, http.StatusOK)
+	if len(list) != 1 {
+		t.Fatalf("atomic bulk was not rolled back: %+v", list)
+	}
+
+	// partial: неудачные операции не мешают остальным
+	resp = mustDoJSON[bulkResponseDTO](t, http.MethodPost, srv.URL+"/notes/bulk?mode=partial", []map[string]any{
+		{"op": "create", "title": "new", "content": "c"},
+		{"op": "create", "title": "bad notebook", "content": "c", "notebookId": 999999},
+		{"op": "update", "id": existing.ID, "title": "updated", "content": "c"},
+		{"op": "delete", "id": existing.ID + 1000},
+		{"op": "frob"},
+	}, http.StatusOK)
+	wantStatus := []int{http.StatusCreated, http.StatusBadRequest, http.StatusOK, http.StatusNotFound, http.StatusBadRequest}
+	if !resp.Committed || len(resp.Results) != len(wantStatus) {
+		t.Fatalf("unexpected partial response: %+v", resp)
+	}
+	for i, st := range wantStatus {
+		if resp.Results[i].Status != st || resp.Results[i].Index != i {
+			t.Fatalf("result %d: got %+v, want status %d", i, resp.Results[i], st)
+		}
+	}
+	if resp.Results[0].ID == 0 || resp.Results[1].Error != "invalid_notebook" || resp.Results[4].Error != "invalid_op" {
+		t.Fatalf("unexpected partial results: %+v", resp.Results)
+	}
+
+	list = mustDoJSON[[]noteDTO](t, http.MethodGet, srv.URL+"/notes", nil, http.StatusOK)
+	if len(list) != 2 || list[0].Title != "new" || list[1].Title != "updated" {
+		t.Fatalf("unexpected notes after partial bulk: %+v", list)
+	}
+}
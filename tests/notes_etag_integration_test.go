@@ -0,0 +1,99 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// doWithHeaders выполняет запрос с дополнительными заголовками и возвращает ответ с прочитанным телом.
+func doWithHeaders(t *testing.T, method, url string, body any, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("json marshal: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var raw bytes.Buffer
+	_, _ = raw.ReadFrom(resp.Body)
+	return resp, raw.Bytes()
+}
+
+func TestNotesETag(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	created := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "t", Content: "c"}, http.StatusCreated)
+	noteURL := urlf(srv.URL+"/notes/%d", created.ID)
+
+	resp, _ := doWithHeaders(t, http.MethodGet, noteURL, nil, nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %q", resp.StatusCode, etag)
+	}
+
+	resp, _ = doWithHeaders(t, http.MethodGet, noteURL, nil, map[string]string{"If-None-Match": "W/" + etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", resp.StatusCode)
+	}
+
+	// обновление с актуальным ETag проходит и выдаёт новый
+	resp, body := doWithHeaders(t, http.MethodPut, noteURL, updateReq{Title: "t2", Content: "c2"}, map[string]string{"If-Match": etag})
+	newETag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || newETag == "" || newETag == etag {
+		t.Fatalf("expected 200 with new ETag, got %d %q: %s", resp.StatusCode, newETag, body)
+	}
+
+	// устаревший ETag — 412, заметка не меняется
+	resp, body = doWithHeaders(t, http.MethodPut, noteURL, updateReq{Title: "lost", Content: "lost"}, map[string]string{"If-Match": etag})
+	if resp.StatusCode != http.StatusPreconditionFailed || !bytes.Contains(body, []byte(`"precondition_failed"`)) {
+		t.Fatalf("expected 412 precondition_failed, got %d: %s", resp.StatusCode, body)
+	}
+	got := mustDoJSON[noteDTO](t, http.MethodGet, noteURL, nil, http.StatusOK)
+	if got.Title != "t2" {
+		t.Fatalf("stale update was applied: %+v", got)
+	}
+
+	resp, _ = doWithHeaders(t, http.MethodDelete, noteURL, nil, map[string]string{"If-Match": etag})
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 on stale delete, got %d", resp.StatusCode)
+	}
+	resp, _ = doWithHeaders(t, http.MethodDelete, noteURL, nil, map[string]string{"If-Match": newETag})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	resp, _ = doWithHeaders(t, http.MethodDelete, noteURL, nil, map[string]string{"If-Match": newETag})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing note, got %d", resp.StatusCode)
+	}
+}
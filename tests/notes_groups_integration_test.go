@@ -0,0 +1,137 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type namedDTO struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	NoteCount int64  `json:"noteCount"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type organizedNoteDTO struct {
+	noteDTO
+	NotebookID *int64   `json:"notebookId"`
+	Tags       []string `json:"tags"`
+}
+
+type organizedPageDTO struct {
+	Items []organizedNoteDTO `json:"items"`
+	Page  struct {
+		Limit      int    `json:"limit"`
+		Sort       string `json:"sort"`
+		NextCursor string `json:"nextCursor"`
+		Next       string `json:"next"`
+	} `json:"page"`
+}
+
+func TestNotesTagsAndNotebooks(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	work := mustDoJSON[namedDTO](t, http.MethodPost, srv.URL+"/notebooks", map[string]string{"name": "Work"}, http.StatusCreated)
+	mustErrorCode(t, http.MethodPost, srv.URL+"/notebooks", map[string]string{"name": " Work "}, http.StatusConflict, "name_taken")
+	mustErrorCode(t, http.MethodPost, srv.URL+"/tags", map[string]string{"name": " "}, http.StatusBadRequest, "name_required")
+
+	a := mustDoJSON[organizedNoteDTO](t, http.MethodPost, srv.URL+"/notes", map[string]any{
+		"title": "a", "content": "c", "notebookId": work.ID, "tags": []string{"urgent", "go"},
+	}, http.StatusCreated)
+	if a.NotebookID == nil || *a.NotebookID != work.ID || len(a.Tags) != 2 || a.Tags[0] != "go" {
+		t.Fatalf("unexpected created note: %+v", a)
+	}
+	b := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "b", Content: "c"}, http.StatusCreated)
+	mustErrorCode(t, http.MethodPost, srv.URL+"/notes", map[string]any{"title": "x", "content": "y", "notebookId": 999999},
+		http.StatusBadRequest, "invalid_notebook")
+
+	tagged := mustDoJSON[organizedNoteDTO](t, http.MethodPut, urlf(srv.URL+"/notes/%d/tags", b.ID),
+		map[string]any{"tags": []string{"go"}}, http.StatusOK)
+	if len(tagged.Tags) != 1 || tagged.Tags[0] != "go" {
+		t.Fatalf("unexpected tagged note: %+v", tagged)
+	}
+
+	page := mustDoJSON[organizedPageDTO](t, http.MethodGet, srv.URL+"/notes?tag=go&tag=urgent", nil, http.StatusOK)
+	if len(page.Items) != 1 || page.Items[0].ID != a.ID {
+		t.Fatalf("unexpected tag filter result: %+v", page.Items)
+	}
+	page = mustDoJSON[organizedPageDTO](t, http.MethodGet, urlf(srv.URL+"/notes?notebook=%d", work.ID), nil, http.StatusOK)
+	if len(page.Items) != 1 || page.Items[0].ID != a.ID {
+		t.Fatalf("unexpected notebook filter result: %+v", page.Items)
+	}
+
+	tags := mustDoJSON[[]namedDTO](t, http.MethodGet, srv.URL+"/tags", nil, http.StatusOK)
+	if len(tags) != 2 || tags[0].Name != "go" || tags[0].NoteCount != 2 || tags[1].NoteCount != 1 {
+		t.Fatalf("unexpected tag counts: %+v", tags)
+	}
+
+	// удаление блокнота оставляет заметки без блокнота
+	mustDoNoBody(t, http.MethodDelete, urlf(srv.URL+"/notebooks/%d", work.ID), http.StatusNoContent)
+	got := mustDoJSON[organizedNoteDTO](t, http.MethodGet, urlf(srv.URL+"/notes/%d", a.ID), nil, http.StatusOK)
+	if got.NotebookID != nil {
+		t.Fatalf("expected note without notebook, got %+v", got)
+	}
+	mustErrorCode(t, http.MethodPut, urlf(srv.URL+"/notes/%d/notebook", a.ID), map[string]any{"notebookId": work.ID},
+		http.StatusBadRequest, "invalid_notebook")
+}
+
+func TestNotesGroupChangesETag(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	work := mustDoJSON[namedDTO](t, http.MethodPost, srv.URL+"/notebooks", map[string]string{"name": "Work"}, http.StatusCreated)
+	n := mustDoJSON[organizedNoteDTO](t, http.MethodPost, srv.URL+"/notes", map[string]any{
+		"title": "a", "content": "c", "notebookId": work.ID, "tags": []string{"go"},
+	}, http.StatusCreated)
+	noteURL := urlf(srv.URL+"/notes/%d", n.ID)
+	tags := mustDoJSON[[]namedDTO](t, http.MethodGet, srv.URL+"/tags", nil, http.StatusOK)
+
+	etag := func() string {
+		t.Helper()
+		resp, _ := doWithHeaders(t, http.MethodGet, noteURL, nil, nil)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" {
+			t.Fatalf("get note: %d, ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+		}
+		return resp.Header.Get("ETag")
+	}
+	mustChange := func(step, old string) {
+		t.Helper()
+		resp, _ := doWithHeaders(t, http.MethodGet, noteURL, nil, map[string]string{"If-None-Match": old})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: If-None-Match with old ETag returned %d, want 200", step, resp.StatusCode)
+		}
+	}
+
+	old := etag()
+	mustDoJSON[namedDTO](t, http.MethodPut, urlf(srv.URL+"/tags/%d", tags[0].ID), map[string]string{"name": "golang"}, http.StatusOK)
+	mustChange("rename tag", old)
+	if got := mustDoJSON[organizedNoteDTO](t, http.MethodGet, noteURL, nil, http.StatusOK); len(got.Tags) != 1 || got.Tags[0] != "golang" {
+		t.Fatalf("unexpected tags after rename: %+v", got)
+	}
+
+	old = etag()
+	mustDoJSON[namedDTO](t, http.MethodPut, urlf(srv.URL+"/notebooks/%d", work.ID), map[string]string{"name": "Job"}, http.StatusOK)
+	mustChange("rename notebook", old)
+
+	old = etag()
+	mustDoNoBody(t, http.MethodDelete, urlf(srv.URL+"/tags/%d", tags[0].ID), http.StatusNoContent)
+	mustChange("delete tag", old)
+
+	old = etag()
+	mustDoNoBody(t, http.MethodDelete, urlf(srv.URL+"/notebooks/%d", work.ID), http.StatusNoContent)
+	mustChange("delete notebook", old)
+}
@@ -8,22 +8,9 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log/slog"
 	"net/http"
-	"net/http/httptest"
-	"path/filepath"
 	"testing"
 	"time"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
-
-	"github.com/Illusiard/miniapi/internal/caps"
-	"github.com/Illusiard/miniapi/internal/meta"
-	"github.com/Illusiard/miniapi/internal/migrations"
-	"github.com/Illusiard/miniapi/internal/store"
-	"github.com/Illusiard/miniapi/modules/notes"
 )
 
 type noteDTO struct {
@@ -32,6 +19,16 @@ type noteDTO struct {
 	Content   string `json:"content"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
+
+	// заполняются только в списке заметок
+	Excerpt string       `json:"excerpt"`
+	Outline []headingDTO `json:"outline"`
+}
+
+type headingDTO struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
 }
 
 type createReq struct {
@@ -50,60 +47,7 @@ func TestNotesCRUDL(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	// 1) Postgres container
-	pg, err := tcpostgres.Run(ctx,
-		"postgres:16-alpine",
-		tcpostgres.WithDatabase("miniapi"),
-		tcpostgres.WithUsername("miniapi"),
-		tcpostgres.WithPassword("miniapi"),
-	)
-	if err != nil {
-		t.Fatalf("start postgres: %v", err)
-	}
-	t.Cleanup(func() { _ = pg.Terminate(context.Background()) })
-
-	dbURL, err := pg.ConnectionString(ctx, "sslmode=disable")
-	if err != nil {
-		t.Fatalf("conn string: %v", err)
-	}
-
-	waitForPostgres(t, ctx, dbURL, 20*time.Second)
-
-	// 2) Migrations
-	root := projectRoot(t)
-	migPath := filepath.Join(root, "migrations")
-	if err := migrations.New(migPath, dbURL).Up(); err != nil {
-		t.Fatalf("migrate up: %v", err)
-	}
-
-	// 3) DB pool + store
-	pool, err := pgxpool.New(ctx, dbURL)
-	if err != nil {
-		t.Fatalf("pgxpool: %v", err)
-	}
-	t.Cleanup(pool.Close)
-
-	st := store.New(pool)
-
-	// 4) Router + module
-	r := chi.NewRouter()
-	metaReg := meta.New()
-
-	log := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
-
-	setup := caps.Setup{
-		Routes: caps.NewChiRoutes(r),
-		Meta:   metaReg,
-		Store:  st,
-		Log:    log,
-	}
-
-	if err := notes.New().Register(setup); err != nil {
-		t.Fatalf("register notes: %v", err)
-	}
-
-	srv := httptest.NewServer(r)
-	t.Cleanup(srv.Close)
+	srv := startNotesServer(t, ctx)
 
 	// 5) CREATE
 	created := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{
@@ -0,0 +1,81 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type notesPageDTO struct {
+	Items []noteDTO `json:"items"`
+	Page  struct {
+		Limit      int    `json:"limit"`
+		Offset     *int   `json:"offset"`
+		Sort       string `json:"sort"`
+		NextCursor string `json:"nextCursor"`
+		Next       string `json:"next"`
+	} `json:"page"`
+}
+
+func TestNotesListPagination(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	for i := 1; i <= 5; i++ {
+		title := fmt.Sprintf("page note %d", i)
+		if i == 5 {
+			title = "other"
+		}
+		mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: title, Content: "c"}, http.StatusCreated)
+	}
+
+	// legacy shape without parameters
+	legacy := mustDoJSON[[]noteDTO](t, http.MethodGet, srv.URL+"/notes", nil, http.StatusOK)
+	if len(legacy) != 5 || legacy[0].ID < legacy[4].ID {
+		t.Fatalf("unexpected legacy list: %+v", legacy)
+	}
+
+	// keyset: walk all pages following next links
+	seen := map[int64]bool{}
+	next := "/notes?limit=2&sort=id&title=page"
+	pages := 0
+	for next != "" {
+		p := mustDoJSON[notesPageDTO](t, http.MethodGet, srv.URL+next, nil, http.StatusOK)
+		for _, n := range p.Items {
+			if seen[n.ID] {
+				t.Fatalf("note %d returned twice", n.ID)
+			}
+			seen[n.ID] = true
+		}
+		next = p.Page.Next
+		pages++
+	}
+	if len(seen) != 4 || pages != 2 {
+		t.Fatalf("expected 4 filtered notes on 2 pages, got %d notes on %d pages", len(seen), pages)
+	}
+
+	// offset mode
+	p := mustDoJSON[notesPageDTO](t, http.MethodGet, srv.URL+"/notes?limit=2&offset=4&sort=id", nil, http.StatusOK)
+	if len(p.Items) != 1 || p.Items[0].Title != "other" || p.Page.Offset == nil || *p.Page.Offset != 4 || p.Page.Next != "" {
+		t.Fatalf("unexpected offset page: %+v", p)
+	}
+
+	// created_at range that excludes everything
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	empty := mustDoJSON[notesPageDTO](t, http.MethodGet, srv.URL+"/notes?createdFrom="+future, nil, http.StatusOK)
+	if len(empty.Items) != 0 {
+		t.Fatalf("expected no notes after %s, got %d", future, len(empty.Items))
+	}
+
+	mustErrorCode(t, http.MethodGet, srv.URL+"/notes?sort=content", nil, http.StatusBadRequest, "invalid_sort")
+	mustErrorCode(t, http.MethodGet, srv.URL+"/notes?limit=1000", nil, http.StatusBadRequest, "invalid_limit")
+}
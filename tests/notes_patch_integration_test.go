@@ -0,0 +1,79 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNotesPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	created := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "title", Content: "content"}, http.StatusCreated)
+	noteURL := urlf(srv.URL+"/notes/%d", created.ID)
+
+	decode := func(body []byte) noteDTO {
+		t.Helper()
+		var n noteDTO
+		if err := json.Unmarshal(body, &n); err != nil {
+			t.Fatalf("decode note: %v: %s", err, body)
+		}
+		return n
+	}
+
+	// merge patch меняет только title
+	resp, body := doWithHeaders(t, http.MethodPatch, noteURL, map[string]any{"title": "renamed"},
+		map[string]string{"Content-Type": "application/merge-patch+json"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("merge patch: status %d: %s", resp.StatusCode, body)
+	}
+	if n := decode(body); n.Title != "renamed" || n.Content != "content" {
+		t.Fatalf("unexpected merge patch result: %+v", n)
+	}
+
+	// json patch с test и copy
+	ops := []map[string]any{
+		{"op": "test", "path": "/title", "value": "renamed"},
+		{"op": "copy", "from": "/title", "path": "/content"},
+	}
+	resp, body = doWithHeaders(t, http.MethodPatch, noteURL, ops, map[string]string{"Content-Type": "application/json-patch+json"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("json patch: status %d: %s", resp.StatusCode, body)
+	}
+	if n := decode(body); n.Title != "renamed" || n.Content != "renamed" {
+		t.Fatalf("unexpected json patch result: %+v", n)
+	}
+
+	ops = []map[string]any{{"op": "test", "path": "/title", "value": "title"}}
+	resp, _ = doWithHeaders(t, http.MethodPatch, noteURL, ops, map[string]string{"Content-Type": "application/json-patch+json"})
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 on failed test op, got %d", resp.StatusCode)
+	}
+
+	resp, _ = doWithHeaders(t, http.MethodPatch, noteURL, map[string]any{"title": nil},
+		map[string]string{"Content-Type": "application/merge-patch+json"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for null title, got %d", resp.StatusCode)
+	}
+
+	resp, _ = doWithHeaders(t, http.MethodPatch, noteURL, map[string]any{"title": "x"}, nil)
+	if resp.StatusCode != http.StatusUnsupportedMediaType || resp.Header.Get("Accept-Patch") == "" {
+		t.Fatalf("expected 415 with Accept-Patch, got %d", resp.StatusCode)
+	}
+
+	resp, _ = doWithHeaders(t, http.MethodPatch, urlf(srv.URL+"/notes/%d", created.ID+1000), map[string]any{"title": "x"},
+		map[string]string{"Content-Type": "application/merge-patch+json"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type renderedNoteDTO struct {
+	noteDTO
+	HTML string `json:"html"`
+}
+
+func TestNotesRender(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	content := "# Plan\n\nShip **v2** <script>alert(1)</script> [docs](javascript:alert(1))\n\n## Steps\n\n- one\n- two"
+	n := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "md", Content: content}, http.StatusCreated)
+
+	got := mustDoJSON[renderedNoteDTO](t, http.MethodGet, urlf(srv.URL, "notes", n.ID)+"?render=html", nil, http.StatusOK)
+	if got.Content != content || !strings.Contains(got.HTML, `<h1 id="plan">Plan</h1>`) ||
+		!strings.Contains(got.HTML, "<strong>v2</strong> &lt;script&gt;") || strings.Contains(got.HTML, "javascript:") {
+		t.Fatalf("unexpected rendered note: %+v", got)
+	}
+
+	resp, raw := doWithHeaders(t, http.MethodGet, urlf(srv.URL, "notes", n.ID), nil, map[string]string{"Accept": "text/html"})
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || string(raw) != got.HTML {
+		t.Fatalf("unexpected html response: %d %q %s", resp.StatusCode, resp.Header.Get("Content-Type"), raw)
+	}
+	if resp.Header.Get("Content-Security-Policy") == "" || resp.Header.Get("ETag") == "" {
+		t.Fatalf("missing headers: %v", resp.Header)
+	}
+
+	pageTag := resp.Header.Get("ETag")
+
+	// без render — прежний JSON без html
+	resp, raw = doWithHeaders(t, http.MethodGet, urlf(srv.URL, "notes", n.ID), nil, nil)
+	var plain map[string]any
+	if err := json.Unmarshal(raw, &plain); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get note: %d %s", resp.StatusCode, raw)
+	}
+	jsonTag := resp.Header.Get("ETag")
+	if jsonTag == pageTag {
+		t.Fatalf("HTML and JSON share ETag %s", jsonTag)
+	}
+	// тег одного представления не валидирует другое
+	resp, _ = doWithHeaders(t, http.MethodGet, urlf(srv.URL, "notes", n.ID), nil,
+		map[string]string{"Accept": "text/html", "If-None-Match": jsonTag})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("html with JSON ETag: status %d, want 200", resp.StatusCode)
+	}
+	resp, _ = doWithHeaders(t, http.MethodGet, urlf(srv.URL, "notes", n.ID), nil,
+		map[string]string{"Accept": "text/html", "If-None-Match": pageTag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("html with its ETag: status %d, want 304", resp.StatusCode)
+	}
+	resp, _ = doWithHeaders(t, http.MethodGet, urlf(srv.URL, "notes", n.ID), nil, map[string]string{"If-None-Match": pageTag})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("json with HTML ETag: status %d, want 200", resp.StatusCode)
+	}
+	if _, ok := plain["html"]; ok {
+		t.Fatalf("html must be opt-in: %s", raw)
+	}
+
+	mustErrorCode(t, http.MethodGet, urlf(srv.URL, "notes", n.ID)+"?render=pdf", nil, http.StatusBadRequest, "invalid_render")
+
+	list := mustDoJSON[[]noteDTO](t, http.MethodGet, srv.URL+"/notes", nil, http.StatusOK)
+	if len(list) != 1 || list[0].Excerpt != "Ship v2 <script>alert(1)</script> docs one two" {
+		t.Fatalf("unexpected excerpt: %+v", list)
+	}
+	want := []headingDTO{{1, "Plan", "plan"}, {2, "Steps", "steps"}}
+	if len(list[0].Outline) != len(want) || list[0].Outline[0] != want[0] || list[0].Outline[1] != want[1] {
+		t.Fatalf("unexpected outline: %+v", list[0].Outline)
+	}
+}
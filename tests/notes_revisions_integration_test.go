@@ -0,0 +1,86 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type revisionDTO struct {
+	NoteID    int64  `json:"noteId"`
+	Rev       int    `json:"rev"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	Action    string `json:"action"`
+	Author    string `json:"author"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type revisionPageDTO struct {
+	Items []revisionDTO `json:"items"`
+	Page  struct {
+		Limit  int    `json:"limit"`
+		Offset *int   `json:"offset"`
+		Sort   string `json:"sort"`
+		Next   string `json:"next"`
+	} `json:"page"`
+}
+
+func TestNotesRevisions(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	created := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "v1", Content: "line a\nline b"}, http.StatusCreated)
+	noteURL := urlf(srv.URL+"/notes/%d", created.ID)
+
+	resp, body := doWithHeaders(t, http.MethodPut, noteURL, updateReq{Title: "v2", Content: "line a\nline c"},
+		map[string]string{"X-Actor": "alice"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update: status %d: %s", resp.StatusCode, body)
+	}
+	mustDoJSON[noteDTO](t, http.MethodPut, noteURL, updateReq{Title: "v3", Content: "line d"}, http.StatusOK)
+
+	revs := mustDoJSON[revisionPageDTO](t, http.MethodGet, noteURL+"/revisions", nil, http.StatusOK)
+	if len(revs.Items) != 2 || revs.Items[0].Rev != 2 || revs.Items[0].Title != "v2" ||
+		revs.Items[1].Rev != 1 || revs.Items[1].Title != "v1" || revs.Items[1].Author != "alice" || revs.Items[1].Action != "update" {
+		t.Fatalf("unexpected revisions: %+v", revs.Items)
+	}
+
+	rev := mustDoJSON[revisionDTO](t, http.MethodGet, noteURL+"/revisions/1", nil, http.StatusOK)
+	if rev.Content != "line a\nline b" {
+		t.Fatalf("unexpected revision: %+v", rev)
+	}
+	mustErrorCode(t, http.MethodGet, noteURL+"/revisions/9", nil, http.StatusNotFound, "not_found")
+
+	resp, body = doWithHeaders(t, http.MethodGet, noteURL+"/revisions/1/diff?to=2", nil, nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "-line b\n+line c\n") {
+		t.Fatalf("unexpected diff: %d\n%s", resp.StatusCode, body)
+	}
+
+	// откат сам сохраняет ревизию с текущим состоянием
+	restored := mustDoJSON[noteDTO](t, http.MethodPost, noteURL+"/revisions/1/restore", nil, http.StatusOK)
+	if restored.Title != "v1" || restored.Content != "line a\nline b" {
+		t.Fatalf("unexpected rolled back note: %+v", restored)
+	}
+	revs = mustDoJSON[revisionPageDTO](t, http.MethodGet, noteURL+"/revisions?limit=1", nil, http.StatusOK)
+	if len(revs.Items) != 1 || revs.Items[0].Rev != 3 || revs.Items[0].Title != "v3" || revs.Items[0].Action != "rollback" || revs.Page.Next == "" {
+		t.Fatalf("unexpected latest revision: %+v", revs)
+	}
+
+	// история удалённой заметки доступна, откат — нет
+	mustDoNoBody(t, http.MethodDelete, noteURL, http.StatusNoContent)
+	revs = mustDoJSON[revisionPageDTO](t, http.MethodGet, noteURL+"/revisions", nil, http.StatusOK)
+	if len(revs.Items) != 4 || revs.Items[0].Action != "delete" {
+		t.Fatalf("expected delete revision, got %+v", revs.Items)
+	}
+	mustErrorCode(t, http.MethodPost, noteURL+"/revisions/1/restore", nil, http.StatusNotFound, "not_found")
+}
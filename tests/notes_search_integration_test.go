@@ -0,0 +1,75 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type searchHitDTO struct {
+	noteDTO
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type searchPageDTO struct {
+	Items []searchHitDTO `json:"items"`
+	Page  struct {
+		Limit      int    `json:"limit"`
+		Offset     *int   `json:"offset"`
+		Sort       string `json:"sort"`
+		NextCursor string `json:"nextCursor"`
+		Next       string `json:"next"`
+	} `json:"page"`
+}
+
+func TestNotesSearch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	for _, in := range []createReq{
+		{Title: "Postgres tips", Content: "Use a GIN index for full-text search over tsvector columns."},
+		{Title: "Shopping", Content: "milk, bread, postgres book"},
+		{Title: "Unrelated", Content: "nothing to see here"},
+	} {
+		mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", in, http.StatusCreated)
+	}
+
+	p := mustDoJSON[searchPageDTO](t, http.MethodGet, srv.URL+"/notes/search?q=postgres", nil, http.StatusOK)
+	if len(p.Items) != 2 {
+		t.Fatalf("expected 2 hits, got %+v", p.Items)
+	}
+	if p.Items[0].Title != "Postgres tips" || p.Items[0].Rank < p.Items[1].Rank {
+		t.Fatalf("expected title match to rank first: %+v", p.Items)
+	}
+	if !strings.Contains(p.Items[1].Snippet, "<mark>postgres</mark>") {
+		t.Fatalf("expected highlighted snippet, got %q", p.Items[1].Snippet)
+	}
+
+	// cursor pagination over ranked results
+	first := mustDoJSON[searchPageDTO](t, http.MethodGet, srv.URL+"/notes/search?q=postgres&limit=1", nil, http.StatusOK)
+	if len(first.Items) != 1 || first.Page.Next == "" {
+		t.Fatalf("expected first page with next link: %+v", first)
+	}
+	second := mustDoJSON[searchPageDTO](t, http.MethodGet, srv.URL+first.Page.Next, nil, http.StatusOK)
+	if len(second.Items) != 1 || second.Items[0].ID == first.Items[0].ID || second.Page.Next != "" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+
+	// websearch syntax: exclusion
+	ex := mustDoJSON[searchPageDTO](t, http.MethodGet, srv.URL+"/notes/search?q=postgres+-milk", nil, http.StatusOK)
+	if len(ex.Items) != 1 || ex.Items[0].Title != "Postgres tips" {
+		t.Fatalf("unexpected websearch result: %+v", ex.Items)
+	}
+
+	mustErrorCode(t, http.MethodGet, srv.URL+"/notes/search", nil, http.StatusBadRequest, "query_required")
+}
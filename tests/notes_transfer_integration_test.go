@@ -0,0 +1,223 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/meta"
+	"github.com/Illusiard/miniapi/internal/store"
+	"github.com/Illusiard/miniapi/modules/notes"
+)
+
+type importErrorDTO struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type importSummaryDTO struct {
+	Inserted int              `json:"inserted"`
+	Rejected int              `json:"rejected"`
+	IDs      []int64          `json:"ids"`
+	Errors   []importErrorDTO `json:"errors"`
+}
+
+// doRaw отправляет тело как есть с указанным Content-Type.
+func doRaw(t *testing.T, method, url, contentType, body string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("do request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var raw bytes.Buffer
+	_, _ = raw.ReadFrom(resp.Body)
+	return resp, raw.Bytes()
+}
+
+func mustImport(t *testing.T, url, contentType, body string) importSummaryDTO {
+	t.Helper()
+
+	resp, raw := doRaw(t, http.MethodPost, url, contentType, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("import: got %d, body=%s", resp.StatusCode, raw)
+	}
+	var sum importSummaryDTO
+	if err := json.Unmarshal(raw, &sum); err != nil {
+		t.Fatalf("decode summary: %v, body=%s", err, raw)
+	}
+	return sum
+}
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatalf("parse time %q: %v", s, err)
+	}
+	return ts
+}
+
+func TestNotesImportExport(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	nb := mustDoJSON[namedDTO](t, http.MethodPost, srv.URL+"/notebooks", map[string]string{"name": "imported"}, http.StatusCreated)
+
+	// CSV: вторая и четвёртая строки отклоняются, остальные вставляются
+	csvBody := "title,content,notebookId,tags,createdAt\n" +
+		"first,\"a, b\"," + strconv.FormatInt(nb.ID, 10) + ",go;work,2023-01-02T03:04:05Z\n" +
+		",no title,,,\n" +
+		"second,c,,,\n" +
+		"bad notebook,c,999999,,\n"
+	sum := mustImport(t, srv.URL+"/notes/import", "text/csv", csvBody)
+	if sum.Inserted != 2 || sum.Rejected != 2 || len(sum.IDs) != 2 {
+		t.Fatalf("unexpected csv summary: %+v", sum)
+	}
+	if sum.Errors[0].Line != 3 || sum.Errors[0].Error != "title_and_content_required" ||
+		sum.Errors[1].Line != 5 || sum.Errors[1].Error != "invalid_notebook" {
+		t.Fatalf("unexpected csv rejects: %+v", sum.Errors)
+	}
+
+	// NDJSON с ?format= важнее Content-Type
+	ndjson := `{"title": "third", "content": "c", "tags": ["go"]}` + "\n" + `{"title": 1}` + "\n"
+	sum = mustImport(t, srv.URL+"/notes/import?format=ndjson", "text/plain", ndjson)
+	if sum.Inserted != 1 || sum.Rejected != 1 || sum.Errors[0].Line != 2 || sum.Errors[0].Error != "invalid_json" {
+		t.Fatalf("unexpected ndjson summary: %+v", sum)
+	}
+
+	resp, raw := doRaw(t, http.MethodPost, srv.URL+"/notes/import", "text/csv", "title,colour\nx,y\n")
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(raw), "unknown_column") {
+		t.Fatalf("expected unknown_column, got %d %s", resp.StatusCode, raw)
+	}
+
+	// экспорт NDJSON по умолчанию, заметки в корзине не попадают
+	mustDoNoBody(t, http.MethodDelete, urlf(srv.URL, "notes", sum.IDs[0]), http.StatusNoContent)
+
+	resp, raw = doWithHeaders(t, http.MethodGet, srv.URL+"/notes/export", nil, nil)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson") {
+		t.Fatalf("unexpected ndjson export: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var exported []organizedNoteDTO
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for sc.Scan() {
+		var n organizedNoteDTO
+		if err := json.Unmarshal(sc.Bytes(), &n); err != nil {
+			t.Fatalf("decode export line %q: %v", sc.Text(), err)
+		}
+		exported = append(exported, n)
+	}
+	if len(exported) != 2 || exported[0].Title != "first" || exported[1].Title != "second" {
+		t.Fatalf("unexpected ndjson export: %+v", exported)
+	}
+	if !mustTime(t, exported[0].CreatedAt).Equal(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)) ||
+		exported[0].NotebookID == nil || *exported[0].NotebookID != nb.ID || strings.Join(exported[0].Tags, ",") != "go,work" {
+		t.Fatalf("unexpected exported note: %+v", exported[0])
+	}
+
+	resp, raw = doWithHeaders(t, http.MethodGet, srv.URL+"/notes/export", nil, map[string]string{"Accept": "text/csv"})
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		t.Fatalf("unexpected csv export: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	records, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv export: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "id" || records[1][2] != "a, b" || records[1][6] != "go;work" {
+		t.Fatalf("unexpected csv export: %q", records)
+	}
+}
+
+// failFirstTx проваливает первую попытку транзакции ошибкой сериализации,
+// как при конфликте с параллельной транзакцией, и Store её повторяет.
+type failFirstTx struct {
+	caps.Store
+	attempts int
+}
+
+func (s *failFirstTx) RunInTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return s.Store.RunInTx(ctx, func(tx pgx.Tx) error {
+		s.attempts++
+		if err := fn(tx); err != nil || s.attempts > 1 {
+			return err
+		}
+		return &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+	})
+}
+
+// Повтор транзакции импорта не теряет строки, отклонённые в первой попытке.
+func TestNotesImportRetry(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, startPostgres(t, ctx))
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	st := &failFirstTx{Store: store.New(pool, store.WithRetryBackoff(time.Millisecond))}
+	r := chi.NewRouter()
+	if err := notes.New().Register(caps.Setup{
+		Routes: caps.NewChiRoutes(r),
+		Meta:   meta.New(),
+		Store:  st,
+		Log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}); err != nil {
+		t.Fatalf("register notes: %v", err)
+	}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	sum := mustImport(t, srv.URL+"/notes/import", "text/csv", "title,content,notebookId\nok,c,\nbad notebook,c,999999\n")
+	if st.attempts != 2 {
+		t.Fatalf("expected a retried transaction, got %d attempts", st.attempts)
+	}
+	if sum.Inserted != 1 || sum.Rejected != 1 || len(sum.Errors) != 1 ||
+		sum.Errors[0].Line != 3 || sum.Errors[0].Error != "invalid_notebook" {
+		t.Fatalf("unexpected summary after retry: %+v", sum)
+	}
+
+	var n int
+	if err := pool.QueryRow(ctx, `select count(*) from notes`).Scan(&n); err != nil {
+		t.Fatalf("count notes: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 note after retry, got %d", n)
+	}
+}
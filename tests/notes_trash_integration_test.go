@@ -0,0 +1,70 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type trashedNoteDTO struct {
+	noteDTO
+	DeletedAt string `json:"deletedAt"`
+}
+
+type trashPageDTO struct {
+	Items []trashedNoteDTO `json:"items"`
+	Page  struct {
+		Limit  int    `json:"limit"`
+		Offset *int   `json:"offset"`
+		Sort   string `json:"sort"`
+		Next   string `json:"next"`
+	} `json:"page"`
+}
+
+func TestNotesTrash(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	srv := startNotesServer(t, ctx)
+
+	kept := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "kept", Content: "c"}, http.StatusCreated)
+	trashed := mustDoJSON[noteDTO](t, http.MethodPost, srv.URL+"/notes", createReq{Title: "trashed", Content: "c"}, http.StatusCreated)
+	noteURL := urlf(srv.URL+"/notes/%d", trashed.ID)
+
+	mustDoNoBody(t, http.MethodDelete, noteURL, http.StatusNoContent)
+
+	// в корзине заметка не видна обычным эндпоинтам
+	mustErrorCode(t, http.MethodGet, noteURL, nil, http.StatusNotFound, "not_found")
+	mustErrorCode(t, http.MethodPut, noteURL, updateReq{Title: "x", Content: "y"}, http.StatusNotFound, "not_found")
+	mustErrorCode(t, http.MethodDelete, noteURL, nil, http.StatusNotFound, "not_found")
+	list := mustDoJSON[[]noteDTO](t, http.MethodGet, srv.URL+"/notes", nil, http.StatusOK)
+	if len(list) != 1 || list[0].ID != kept.ID {
+		t.Fatalf("trashed note is listed: %+v", list)
+	}
+
+	trash := mustDoJSON[trashPageDTO](t, http.MethodGet, srv.URL+"/notes/trash", nil, http.StatusOK)
+	if len(trash.Items) != 1 || trash.Items[0].ID != trashed.ID || trash.Items[0].DeletedAt == "" {
+		t.Fatalf("unexpected trash: %+v", trash)
+	}
+
+	restored := mustDoJSON[noteDTO](t, http.MethodPost, urlf(srv.URL+"/notes/%d/restore", trashed.ID), nil, http.StatusOK)
+	if restored.ID != trashed.ID || restored.Title != "trashed" {
+		t.Fatalf("unexpected restored note: %+v", restored)
+	}
+	mustDoJSON[noteDTO](t, http.MethodGet, noteURL, nil, http.StatusOK)
+	mustErrorCode(t, http.MethodPost, urlf(srv.URL+"/notes/%d/restore", trashed.ID), nil, http.StatusNotFound, "not_found")
+
+	// purge удаляет безвозвратно
+	mustDoNoBody(t, http.MethodDelete, urlf(srv.URL+"/notes/%d/purge", trashed.ID), http.StatusNoContent)
+	mustErrorCode(t, http.MethodPost, urlf(srv.URL+"/notes/%d/restore", trashed.ID), nil, http.StatusNotFound, "not_found")
+	trash = mustDoJSON[trashPageDTO](t, http.MethodGet, srv.URL+"/notes/trash", nil, http.StatusOK)
+	if len(trash.Items) != 0 {
+		t.Fatalf("expected empty trash, got %+v", trash.Items)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/drift"
+	"github.com/Illusiard/miniapi/internal/meta"
+	"github.com/Illusiard/miniapi/internal/store"
+	"github.com/Illusiard/miniapi/modules/notes"
+)
+
+func TestSchemaDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	st := store.New(pool)
+
+	metaReg := meta.New()
+	if err := notes.New().Register(caps.Setup{
+		Routes: caps.NewChiRoutes(chi.NewRouter()),
+		Meta:   metaReg,
+		Store:  st,
+	}); err != nil {
+		t.Fatalf("register notes: %v", err)
+	}
+	entities := map[string]meta.Entity{}
+	for _, e := range metaReg.Entities() {
+		entities[e.Name] = e
+	}
+
+	check := func(name string) drift.Report {
+		t.Helper()
+		r, err := drift.Check(ctx, st, entities[name])
+		if err != nil {
+			t.Fatalf("check %s: %v", name, err)
+		}
+		return r
+	}
+
+	t.Run("migrated schema matches entities", func(t *testing.T) {
+		for name := range entities {
+			if err := check(name).Err(); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+		// служебный tsvector не описан в Note, но и вставке не мешает
+		extra := check("Note").ExtraColumns
+		if len(extra) != 1 || extra[0].Name != "search" || extra[0].Required {
+			t.Fatalf("Note extra columns = %+v, want only optional search", extra)
+		}
+	})
+
+	t.Run("drift is reported", func(t *testing.T) {
+		if err := st.Exec(ctx, `
+alter table notes drop column notebook_id;
+alter table notes alter column title drop not null;
+alter table notes add column owner_id bigint not null default 0;
+alter table notes alter column owner_id drop default;
+alter table notes alter column updated_at type text`); err != nil {
+			t.Fatalf("alter notes: %v", err)
+		}
+
+		r := check("Note")
+		if want := []string{"notebook_id"}; !reflect.DeepEqual(r.MissingColumns, want) {
+			t.Fatalf("MissingColumns = %v, want %v", r.MissingColumns, want)
+		}
+		want := []drift.Mismatch{
+			{Column: "title", FieldType: "string", ColumnType: "text", ColumnNullable: true},
+			{Column: "updated_at", FieldType: "datetime", ColumnType: "text"},
+		}
+		if !reflect.DeepEqual(r.Mismatches, want) {
+			t.Fatalf("Mismatches = %+v, want %+v", r.Mismatches, want)
+		}
+		var owner *drift.Column
+		for i, c := range r.ExtraColumns {
+			if c.Name == "owner_id" {
+				owner = &r.ExtraColumns[i]
+			}
+		}
+		if owner == nil || !owner.Required {
+			t.Fatalf("ExtraColumns = %+v, want required owner_id", r.ExtraColumns)
+		}
+		if r.Err() == nil {
+			t.Fatal("Err = nil, want drift")
+		}
+	})
+
+	t.Run("schema-qualified table", func(t *testing.T) {
+		if err := st.Exec(ctx, `create schema audit; create table audit.events (id bigserial primary key, payload jsonb)`); err != nil {
+			t.Fatalf("create audit.events: %v", err)
+		}
+		r, err := drift.Check(ctx, st, meta.Entity{Name: "Event", Table: "audit.events", Fields: []meta.Field{
+			{Name: "id", Type: "int"},
+			{Name: "payload", Type: "json", Nullable: true},
+		}})
+		if err != nil {
+			t.Fatalf("check audit.events: %v", err)
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("audit.events: %v", err)
+		}
+	})
+
+	t.Run("missing table", func(t *testing.T) {
+		if err := st.Exec(ctx, `drop table note_tags; drop table tags`); err != nil {
+			t.Fatalf("drop tags: %v", err)
+		}
+		if r := check("Tag"); !r.MissingTable || r.Err() == nil {
+			t.Fatalf("report = %+v, want missing table", r)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/store"
+)
+
+// newNamedPool открывает пул с application_name, по которому видно, куда ушёл запрос.
+func newNamedPool(t *testing.T, ctx context.Context, dbURL, name string) *pgxpool.Pool {
+	t.Helper()
+
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["application_name"] = name
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestStoreReplicaRouting(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	// «реплика» — та же база под другим application_name
+	dbURL := startPostgres(t, ctx)
+	primary := newNamedPool(t, ctx, dbURL, "primary")
+	replica := newNamedPool(t, ctx, dbURL, "replica")
+	st := store.New(primary, store.WithReplicas(replica))
+
+	const appName = `select current_setting('application_name')`
+	queryTarget := func(ctx context.Context) string {
+		t.Helper()
+		var name string
+		if err := st.QueryRow(ctx, appName).Scan(&name); err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		return name
+	}
+	txTarget := func(run func(context.Context, func(pgx.Tx) error) error) string {
+		t.Helper()
+		var name string
+		err := run(ctx, func(tx pgx.Tx) error {
+			return tx.QueryRow(ctx, appName).Scan(&name)
+		})
+		if err != nil {
+			t.Fatalf("tx: %v", err)
+		}
+		return name
+	}
+
+	if got := queryTarget(ctx); got != "primary" {
+		t.Fatalf("plain query went to %s", got)
+	}
+	if got := queryTarget(caps.WithReadReplica(ctx)); got != "replica" {
+		t.Fatalf("replica query went to %s", got)
+	}
+	if got := txTarget(st.RunInReadTx); got != "replica" {
+		t.Fatalf("read tx went to %s", got)
+	}
+	if got := txTarget(st.RunInTx); got != "primary" {
+		t.Fatalf("tx went to %s", got)
+	}
+
+	// внутри транзакции чтение остаётся в ней, даже с WithReadReplica
+	err := caps.InTx(ctx, st, func(ctx context.Context, tx pgx.Tx) error {
+		if got := queryTarget(caps.WithReadReplica(ctx)); got != "primary" {
+			t.Fatalf("query inside tx went to %s", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("in tx: %v", err)
+	}
+
+	if got := st.CheckReplicas(ctx); len(got) != 1 || !got[0].Healthy {
+		t.Fatalf("replica status: %+v", got)
+	}
+
+	// упавшая реплика выключается, и чтение уходит в основную базу
+	replica.Close()
+	if got := queryTarget(caps.WithReadReplica(ctx)); got != "primary" {
+		t.Fatalf("fallback query went to %s", got)
+	}
+	if got := st.Replicas(); got[0].Healthy {
+		t.Fatalf("replica must be marked down: %+v", got)
+	}
+	if got := txTarget(st.RunInReadTx); got != "primary" {
+		t.Fatalf("fallback read tx went to %s", got)
+	}
+}
@@ -0,0 +1,242 @@
+//go:build integration
+// +build integration
+
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/store"
+)
+
+func TestStoreSerializationRetry(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, startPostgres(t, ctx))
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, `create table tx_counters (id int primary key, n int not null); insert into tx_counters values (1, 0)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	var logs bytes.Buffer
+	st := store.New(pool, store.WithRetryBackoff(time.Millisecond),
+		store.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	// обе транзакции читают счётчик до того, как любая из них его обновит,
+	// поэтому вторая при фиксации получает 40001 и повторяется
+	var read sync.WaitGroup
+	read.Add(2)
+	var attempts atomic.Int32
+	increment := func() error {
+		first := true
+		return st.RunInTxOpts(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(tx pgx.Tx) error {
+			attempts.Add(1)
+			var n int
+			if err := tx.QueryRow(ctx, `select n from tx_counters where id = 1`).Scan(&n); err != nil {
+				return err
+			}
+			if first {
+				first = false
+				read.Done()
+				read.Wait()
+			}
+			_, err := tx.Exec(ctx, `update tx_counters set n = $1 where id = 1`, n+1)
+			return err
+		})
+	}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- increment() }()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatalf("increment: %v", err)
+		}
+	}
+
+	var n int
+	if err := pool.QueryRow(ctx, `select n from tx_counters where id = 1`).Scan(&n); err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	if n != 2 || attempts.Load() < 3 {
+		t.Fatalf("expected both increments with a retry, got n=%d attempts=%d", n, attempts.Load())
+	}
+	if !strings.Contains(logs.String(), "retrying transaction") || !strings.Contains(logs.String(), "sqlstate=40001") {
+		t.Fatalf("retry was not logged: %s", logs.String())
+	}
+
+	// без повторов ошибка сериализации возвращается вызывающему
+	st = store.New(pool, store.WithMaxRetries(0))
+	read.Add(2)
+	attempts.Store(0)
+	failed := 0
+	for range 2 {
+		go func() { errs <- increment() }()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			failed++
+		}
+	}
+	if failed != 1 || attempts.Load() != 2 {
+		t.Fatalf("expected one failure without retries, got failed=%d attempts=%d", failed, attempts.Load())
+	}
+}
+
+func TestStoreNestedTx(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	pool, err := pgxpool.New(ctx, startPostgres(t, ctx))
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, `create table tx_items (name text primary key)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	st := store.New(pool)
+
+	insert := func(ctx context.Context, name string) error {
+		return st.RunInTx(ctx, func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, `insert into tx_items values ($1)`, name)
+			return err
+		})
+	}
+	names := func(ctx context.Context) string {
+		t.Helper()
+		rows, err := st.Query(ctx, `select name from tx_items order by name`)
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		out, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+		return strings.Join(out, ",")
+	}
+
+	errInner := errors.New("inner failed")
+	err = caps.InTx(ctx, st, func(ctx context.Context, _ pgx.Tx) error {
+		if err := insert(ctx, "a"); err != nil {
+			return err
+		}
+		// неудачный вложенный вызов откатывается до своей точки сохранения
+		err := st.RunInTx(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `insert into tx_items values ('b')`); err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			t.Errorf("expected inner error, got %v", err)
+		}
+		// дубликат ключа во вложенной транзакции не ломает внешнюю
+		if err := insert(ctx, "a"); err == nil {
+			t.Error("expected unique violation")
+		}
+		if err := insert(ctx, "c"); err != nil {
+			return err
+		}
+
+		if got := names(ctx); got != "a,c" {
+			t.Errorf("inside tx: got %q", got)
+		}
+		if got := names(context.Background()); got != "" {
+			t.Errorf("uncommitted rows visible outside tx: %q", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("outer tx: %v", err)
+	}
+	if got := names(ctx); got != "a,c" {
+		t.Fatalf("after commit: got %q", got)
+	}
+
+	// откат внешней транзакции отменяет и уже зафиксированные savepoint'ы
+	_ = caps.InTx(ctx, st, func(ctx context.Context, _ pgx.Tx) error {
+		if err := insert(ctx, "d"); err != nil {
+			return err
+		}
+		return errInner
+	})
+	if got := names(ctx); got != "a,c" {
+		t.Fatalf("after outer rollback: got %q", got)
+	}
+}
+
+func TestStoreIgnoresForeignTx(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	dbURL := startPostgres(t, ctx)
+	newStore := func() *store.PGStore {
+		pool, err := pgxpool.New(ctx, dbURL)
+		if err != nil {
+			t.Fatalf("pgxpool: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		return store.New(pool)
+	}
+	// два хранилища — как две именованные базы; здесь обе смотрят в одну, чтобы видеть строки
+	st, other := newStore(), newStore()
+	if err := st.Exec(ctx, `create table tx_foreign (name text primary key)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	_ = caps.InTx(ctx, st, func(ctx context.Context, _ pgx.Tx) error {
+		if err := st.Exec(ctx, `insert into tx_foreign values ('own')`); err != nil {
+			return err
+		}
+		if err := other.Exec(ctx, `insert into tx_foreign values ('exec')`); err != nil {
+			t.Errorf("other exec: %v", err)
+		}
+		if err := other.RunInTx(ctx, func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, `insert into tx_foreign values ('tx')`)
+			return err
+		}); err != nil {
+			t.Errorf("other tx: %v", err)
+		}
+		return errRollback
+	})
+
+	var names []string
+	rows, err := st.Query(ctx, `select name from tx_foreign order by name`)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if names, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	// откат транзакции st не затронул записи другого хранилища
+	if got := strings.Join(names, ","); got != "exec,tx" {
+		t.Fatalf("got %q, want exec,tx", got)
+	}
+}
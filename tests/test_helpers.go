@@ -9,34 +9,89 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"database/sql"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+
+	"github.com/Illusiard/miniapi/internal/caps"
+	"github.com/Illusiard/miniapi/internal/meta"
+	"github.com/Illusiard/miniapi/internal/migrations"
+	"github.com/Illusiard/miniapi/internal/store"
+	"github.com/Illusiard/miniapi/modules/notes"
 )
 
-func projectRoot(t *testing.T) string {
+// startPostgres поднимает контейнер PostgreSQL и применяет миграции.
+func startPostgres(t *testing.T, ctx context.Context) string {
 	t.Helper()
 
-	dir, err := os.Getwd()
+	pg, err := tcpostgres.Run(ctx,
+		"postgres:16-alpine",
+		tcpostgres.WithDatabase("miniapi"),
+		tcpostgres.WithUsername("miniapi"),
+		tcpostgres.WithPassword("miniapi"),
+	)
 	if err != nil {
-		t.Fatalf("getwd: %v", err)
+		t.Fatalf("start postgres: %v", err)
 	}
+	t.Cleanup(func() { _ = pg.Terminate(context.Background()) })
 
-	for {
-		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
-			return dir
-		}
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			t.Fatalf("project root not found (go.mod)")
-		}
-		dir = parent
+	dbURL, err := pg.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("conn string: %v", err)
 	}
+
+	waitForPostgres(t, ctx, dbURL, 20*time.Second)
+
+	set := migrations.Set{Module: "notes", FS: notes.New().Migrations(), DatabaseURL: dbURL}
+	if err := migrations.New([]migrations.Set{set}).Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	return dbURL
+}
+
+// startNotesServer поднимает Postgres и HTTP-сервер только с модулем notes.
+func startNotesServer(t *testing.T, ctx context.Context) *httptest.Server {
+	t.Helper()
+
+	dbURL := startPostgres(t, ctx)
+
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("pgxpool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	st := store.New(pool)
+
+	r := chi.NewRouter()
+	metaReg := meta.New()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+
+	setup := caps.Setup{
+		Routes: caps.NewChiRoutes(r),
+		Meta:   metaReg,
+		Store:  st,
+		Log:    log,
+	}
+
+	if err := notes.New().Register(setup); err != nil {
+		t.Fatalf("register notes: %v", err)
+	}
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv
 }
 
 func mustDoJSON[T any](t *testing.T, method, url string, body any, wantStatus int) T {